@@ -1,5 +1,7 @@
 package app
 
+import "strconv"
+
 //Language Enum to be used for languges
 type Language int
 
@@ -9,6 +11,12 @@ type Topic int
 //Status status of the game
 type Status int
 
+//Difficulty difficulty level of a question
+type Difficulty int
+
+//QuestionState moderation state of a question, only live questions are played
+type QuestionState int
+
 const (
 	//LastGameIDKey used for the game
 	LastGameIDKey = "last_game_id_key"
@@ -27,6 +35,26 @@ const (
 	Finished
 )
 
+const (
+	//Easy question answered correctly by most players
+	Easy Difficulty = iota + 1
+	//Medium question
+	Medium
+	//Hard question answered correctly by few players
+	Hard
+)
+
+const (
+	//Draft question being written
+	Draft QuestionState = iota + 1
+	//PendingReview question waiting for a review, also used for reported questions
+	PendingReview
+	//Live question used in the games, questions without a state are live
+	Live
+	//Retired question no longer used
+	Retired
+)
+
 const (
 	//English default language
 	English Language = iota + 1
@@ -52,13 +80,51 @@ const (
 )
 
 func (l Language) String() string {
-	return []string{"Default", "English", "Hindi", "Bengali", "Tamil", "Odia"}[l]
+	if info, ok := GetLanguage(l); ok {
+		return info.Name
+	}
+	return "Language" + strconv.Itoa(int(l))
 }
 
 func (t Topic) String() string {
-	return []string{"Default", "India", "Science", "Technology", "World"}[t]
+	if info, ok := GetTopic(t); ok {
+		return info.Name
+	}
+	return "Topic" + strconv.Itoa(int(t))
+}
+
+//Key of the language in the stored questions, it does not change on renames
+func (l Language) Key() string {
+	if info, ok := GetLanguage(l); ok {
+		return info.Key
+	}
+	return "language" + strconv.Itoa(int(l))
+}
+
+//Key of the topic in the stored questions, it does not change on renames
+func (t Topic) Key() string {
+	if info, ok := GetTopic(t); ok {
+		return info.Key
+	}
+	return "topic" + strconv.Itoa(int(t))
 }
 
 func (s Status) String() string {
 	return []string{"Default", "Active", "Disconnected", "Finished"}[s]
 }
+
+func (d Difficulty) String() string {
+	names := []string{"Any", "Easy", "Medium", "Hard"}
+	if d < 0 || int(d) >= len(names) {
+		return "unknown"
+	}
+	return names[d]
+}
+
+func (q QuestionState) String() string {
+	names := []string{"Default", "Draft", "PendingReview", "Live", "Retired"}
+	if q < 0 || int(q) >= len(names) {
+		return "unknown"
+	}
+	return names[q]
+}
@@ -0,0 +1,69 @@
+package app
+
+import (
+	"encoding/json"
+	"sharequiz/app/database"
+	"time"
+)
+
+const activeGamesKey = "active-games"
+
+//GameSummary state of a running or disconnected game for monitoring
+type GameSummary struct {
+	ID             string   `json:"id"`
+	Status         Status   `json:"status,string"`
+	Topic          Topic    `json:"topic,string"`
+	Language       Language `json:"language,string"`
+	Players        []string `json:"players"`
+	QuestionNumber int      `json:"questionNumber"`
+	MaxQuestions   int      `json:"maxQuestions"`
+	ElapsedSeconds int64    `json:"elapsedSeconds"`
+}
+
+//AddActiveGame adds the game to the active games
+func AddActiveGame(gameID string) {
+	database.RedisClient.SAdd(activeGamesKey, gameID)
+}
+
+//RemoveActiveGame removes the game from the active games
+func RemoveActiveGame(gameID string) {
+	database.RedisClient.SRem(activeGamesKey, gameID)
+}
+
+//GetActiveGames returns the summary of the running and disconnected games,
+//finished games are removed from the active games
+func GetActiveGames() ([]GameSummary, error) {
+	gameIDs, err := database.RedisClient.SMembers(activeGamesKey).Result()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().Unix()
+	summaries := make([]GameSummary, 0, len(gameIDs))
+	for _, gameID := range gameIDs {
+		gameData, err := database.RedisClient.Get(gameID).Result()
+		if err != nil {
+			RemoveActiveGame(gameID)
+			continue
+		}
+		game := &Game{}
+		if err := json.Unmarshal([]byte(gameData), game); err != nil || game.Status == Finished {
+			RemoveActiveGame(gameID)
+			continue
+		}
+		players := make([]string, 0, len(game.Players))
+		for playerID := range game.Players {
+			players = append(players, playerID)
+		}
+		summaries = append(summaries, GameSummary{
+			ID:             game.ID,
+			Status:         game.Status,
+			Topic:          game.Topic,
+			Language:       game.Language,
+			Players:        players,
+			QuestionNumber: game.QuestionNumber,
+			MaxQuestions:   game.MaxQuestions,
+			ElapsedSeconds: now - game.CreatedTimestamp,
+		})
+	}
+	return summaries, nil
+}
@@ -0,0 +1,194 @@
+package admin
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+//Credentials login of an admin
+type Credentials struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+//AccountData new admin account or changes to an account
+type AccountData struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+	Role     Role   `json:"role"`
+}
+
+//LoginAdmin starts an admin session, the token is sent as a bearer token
+func LoginAdmin(c *gin.Context) {
+	credentials := Credentials{}
+	if err := c.ShouldBindJSON(&credentials); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "check the username and password",
+		})
+		return
+	}
+	token, err := Login(credentials.Username, credentials.Password, c.ClientIP())
+	if err == ErrTooManyLogins {
+		c.JSON(http.StatusTooManyRequests, gin.H{
+			"message": err.Error(),
+		})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"token": token,
+	})
+}
+
+//LogoutAdmin ends the admin session
+func LogoutAdmin(c *gin.Context) {
+	if err := Logout(bearerToken(c)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "error while logging out",
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"result": "success",
+	})
+}
+
+//GetAccountList lists the admin accounts
+func GetAccountList(c *gin.Context) {
+	accounts, err := GetAccounts()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "error while getting accounts",
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"accounts": accounts,
+	})
+}
+
+//AddAccount creates an admin account
+func AddAccount(c *gin.Context) {
+	data := AccountData{}
+	if err := c.ShouldBindJSON(&data); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "check the account",
+		})
+		return
+	}
+	account, err := CreateAccount(data.Username, data.Password, data.Role)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"account": account.public(),
+	})
+}
+
+//UpdateAccount changes the role or the password of an admin account
+func UpdateAccount(c *gin.Context) {
+	data := AccountData{}
+	if err := c.ShouldBindJSON(&data); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "check the account",
+		})
+		return
+	}
+	account, err := GetAccount(c.Param("username"))
+	if err == ErrAccountNotFound {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": err.Error(),
+		})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "error while updating account",
+		})
+		return
+	}
+	if data.Role != 0 {
+		if err := SetRole(account, data.Role); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"message": err.Error(),
+			})
+			return
+		}
+	}
+	if data.Password != "" {
+		if err := validatePassword(data.Password); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"message": err.Error(),
+			})
+			return
+		}
+		if err := account.setPassword(data.Password); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"message": "error while updating account",
+			})
+			return
+		}
+	}
+	if err := saveAccount(account); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "error while updating account",
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"account": account.public(),
+	})
+}
+
+//RemoveAccount deletes an admin account, admins can not delete themselves
+func RemoveAccount(c *gin.Context) {
+	username := c.Param("username")
+	if account := currentAccount(c); account != nil && account.Username == username {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "can not delete your own account",
+		})
+		return
+	}
+	err := DeleteAccount(username)
+	if err == ErrAccountNotFound {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": err.Error(),
+		})
+		return
+	} else if err == ErrLastSuperadmin {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "error while deleting account",
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"result": "success",
+	})
+}
+
+//GetAudit returns a page of the admin audit log
+func GetAudit(c *gin.Context) {
+	from, size := pageQuery(c)
+	entries, err := GetAuditLog(from, size)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "error while getting the audit log",
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"entries": entries,
+	})
+}
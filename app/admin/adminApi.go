@@ -10,20 +10,6 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-//GetQuestions admin function for getting the question
-func GetQuestions(c *gin.Context) {
-	questions, err := app.GetGameQuestions(app.India, app.English, app.NumOfQuestionsInGame)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"questions": make([]app.Question, 0),
-		})
-	} else {
-		c.JSON(http.StatusOK, gin.H{
-			"questions": questions,
-		})
-	}
-}
-
 //GetGame get game object at any instant
 func GetGame(c *gin.Context) {
 	gameID := c.Query("game_id")
@@ -49,7 +35,7 @@ func GetGame(c *gin.Context) {
 
 //CreateGame creates game for the app
 func CreateGame(c *gin.Context) {
-	gameID, err := app.CreateGame(10, app.Hindi, 2, app.India)
+	gameID, err := app.CreateGame(10, app.Hindi, 2, app.India, nil)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"result": "error",
@@ -72,3 +58,23 @@ func GetOtp(c *gin.Context) {
 func CreateRoom(c *gin.Context) {
 	app.CreateRoom(c)
 }
+
+//GetGameReplay returns the event log of any game step by step, including
+//running games
+func GetGameReplay(c *gin.Context) {
+	replay, err := app.ReplayGame(c.Query("game_id"))
+	if err == app.ErrGameNotFound {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": err.Error(),
+		})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "error while getting the replay",
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"replay": replay,
+	})
+}
@@ -0,0 +1,71 @@
+package admin
+
+import (
+	"encoding/json"
+	"log"
+	"sharequiz/app/database"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	auditLogKey = "admin-audit-log"
+	//AuditLogLimit number of admin actions kept in the audit log
+	AuditLogLimit = 10000
+)
+
+//AuditEntry admin request recorded in the audit log
+type AuditEntry struct {
+	Username  string `json:"username"`
+	Method    string `json:"method"`
+	Path      string `json:"path"`
+	Query     string `json:"query"`
+	Status    int    `json:"status"`
+	Timestamp int64  `json:"timestamp"`
+}
+
+//Audit records every request of the logged in admins in the audit log
+func Audit() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+		account := currentAccount(c)
+		if account == nil {
+			return
+		}
+		entry := AuditEntry{
+			Username:  account.Username,
+			Method:    c.Request.Method,
+			Path:      c.Request.URL.Path,
+			Query:     c.Request.URL.RawQuery,
+			Status:    c.Writer.Status(),
+			Timestamp: time.Now().Unix(),
+		}
+		entryJSON, err := json.Marshal(entry)
+		if err != nil {
+			return
+		}
+		pipe := database.RedisClient.Pipeline()
+		pipe.LPush(auditLogKey, string(entryJSON))
+		pipe.LTrim(auditLogKey, 0, AuditLogLimit-1)
+		if _, err := pipe.Exec(); err != nil {
+			log.Println("error while writing the audit log", err)
+		}
+	}
+}
+
+//GetAuditLog returns a page of the audit log, newest first
+func GetAuditLog(from int, size int) ([]AuditEntry, error) {
+	values, err := database.RedisClient.LRange(auditLogKey, int64(from), int64(from+size-1)).Result()
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]AuditEntry, 0, len(values))
+	for _, value := range values {
+		entry := AuditEntry{}
+		if err := json.Unmarshal([]byte(value), &entry); err == nil {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, nil
+}
@@ -0,0 +1,345 @@
+package admin
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"sharequiz/app/database"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis"
+	"golang.org/x/crypto/bcrypt"
+)
+
+//Role of an admin account, every role can do everything the lower roles can
+type Role int
+
+const (
+	//Viewer can read questions, reports and games
+	Viewer Role = iota + 1
+	//ContentEditor can also change questions and triage reports
+	ContentEditor
+	//Superadmin can also send sms, create games and manage admin accounts
+	Superadmin
+)
+
+const (
+	//SessionDuration time an admin stays logged in
+	SessionDuration = 12 * time.Hour
+	//MaxLoginFailures failed logins of a username or an address before the
+	//logins are blocked for LoginBlockDuration
+	MaxLoginFailures = 5
+	//LoginBlockDuration time the failed logins are remembered
+	LoginBlockDuration = 15 * time.Minute
+	adminAccountsKey   = "admin-accounts"
+	accountContextKey  = "admin-account"
+	//maxPasswordLength bcrypt only uses the first 72 bytes of the password
+	maxPasswordLength = 72
+)
+
+//ErrAccountNotFound returned when no admin account exists for the username
+var ErrAccountNotFound = errors.New("admin account not found")
+
+//ErrTooManyLogins returned while the logins of the username or address are blocked
+var ErrTooManyLogins = errors.New("too many failed logins, try again later")
+
+//ErrLastSuperadmin returned when a change would leave no superadmin
+var ErrLastSuperadmin = errors.New("the last superadmin can not be removed")
+
+//Account admin account, the password is stored as a bcrypt hash
+type Account struct {
+	Username         string `json:"username"`
+	Role             Role   `json:"role"`
+	PasswordHash     string `json:"passwordHash,omitempty"`
+	CreatedTimestamp int64  `json:"createdTimestamp"`
+}
+
+func (r Role) String() string {
+	names := []string{"Default", "Viewer", "ContentEditor", "Superadmin"}
+	if r < 0 || int(r) >= len(names) {
+		return "unknown"
+	}
+	return names[r]
+}
+
+func accountKey(username string) string {
+	return "admin-account-" + username
+}
+
+func sessionKey(token string) string {
+	return "admin-session-" + token
+}
+
+//loginFailuresKey counts the recent failed logins of a username or address
+func loginFailuresKey(name string) string {
+	return "admin-login-failures-" + name
+}
+
+//InitAdmin creates the superadmin from ADMIN_USERNAME and ADMIN_PASSWORD when
+//there are no admin accounts
+func InitAdmin() {
+	username := os.Getenv("ADMIN_USERNAME")
+	password := os.Getenv("ADMIN_PASSWORD")
+	if username == "" || password == "" {
+		return
+	}
+	count, err := database.RedisClient.SCard(adminAccountsKey).Result()
+	if err != nil || count > 0 {
+		return
+	}
+	if _, err := CreateAccount(username, password, Superadmin); err != nil {
+		log.Println("error while creating the superadmin", err)
+	}
+}
+
+//CreateAccount stores a new admin account
+func CreateAccount(username string, password string, role Role) (*Account, error) {
+	if username == "" {
+		return nil, errors.New("username is required")
+	}
+	if err := validatePassword(password); err != nil {
+		return nil, err
+	}
+	if role < Viewer || role > Superadmin {
+		return nil, errors.New("unknown role")
+	}
+	added, err := database.RedisClient.SAdd(adminAccountsKey, username).Result()
+	if err != nil {
+		return nil, err
+	}
+	if added == 0 {
+		return nil, errors.New("username already in use")
+	}
+	account := &Account{
+		Username:         username,
+		Role:             role,
+		CreatedTimestamp: time.Now().Unix(),
+	}
+	if err := account.setPassword(password); err != nil {
+		return nil, err
+	}
+	return account, saveAccount(account)
+}
+
+//GetAccount returns the admin account of the username
+func GetAccount(username string) (*Account, error) {
+	data, err := database.RedisClient.Get(accountKey(username)).Result()
+	if err == redis.Nil {
+		return nil, ErrAccountNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	account := &Account{}
+	if err := json.Unmarshal([]byte(data), account); err != nil {
+		return nil, err
+	}
+	return account, nil
+}
+
+//GetAccounts returns all the admin accounts without their passwords
+func GetAccounts() ([]Account, error) {
+	usernames, err := database.RedisClient.SMembers(adminAccountsKey).Result()
+	if err != nil {
+		return nil, err
+	}
+	accounts := make([]Account, 0, len(usernames))
+	for _, username := range usernames {
+		account, err := GetAccount(username)
+		if err != nil {
+			continue
+		}
+		accounts = append(accounts, account.public())
+	}
+	return accounts, nil
+}
+
+//DeleteAccount deletes the admin account, the last superadmin can not be deleted
+func DeleteAccount(username string) error {
+	account, err := GetAccount(username)
+	if err != nil {
+		return err
+	}
+	if account.Role == Superadmin {
+		if last, err := isLastSuperadmin(account.Username); err != nil {
+			return err
+		} else if last {
+			return ErrLastSuperadmin
+		}
+	}
+	deleted, err := database.RedisClient.Del(accountKey(username)).Result()
+	if err != nil {
+		return err
+	}
+	if deleted == 0 {
+		return ErrAccountNotFound
+	}
+	return database.RedisClient.SRem(adminAccountsKey, username).Err()
+}
+
+//SetRole changes the role of the account, the last superadmin keeps its role
+func SetRole(account *Account, role Role) error {
+	if role < Viewer || role > Superadmin {
+		return errors.New("unknown role")
+	}
+	if account.Role == Superadmin && role != Superadmin {
+		if last, err := isLastSuperadmin(account.Username); err != nil {
+			return err
+		} else if last {
+			return ErrLastSuperadmin
+		}
+	}
+	account.Role = role
+	return nil
+}
+
+//isLastSuperadmin checks if the username is the only superadmin
+func isLastSuperadmin(username string) (bool, error) {
+	accounts, err := GetAccounts()
+	if err != nil {
+		return false, err
+	}
+	for _, account := range accounts {
+		if account.Role == Superadmin && account.Username != username {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+//Login checks the password and starts a session, returning its token. The
+//logins of a username or an address are blocked after MaxLoginFailures
+//failed logins.
+func Login(username string, password string, address string) (string, error) {
+	failureKeys := []string{loginFailuresKey("user-" + username), loginFailuresKey("address-" + address)}
+	for _, key := range failureKeys {
+		failures, err := database.RedisClient.Get(key).Int()
+		if err != nil && err != redis.Nil {
+			return "", err
+		}
+		if failures >= MaxLoginFailures {
+			return "", ErrTooManyLogins
+		}
+	}
+	account, err := GetAccount(username)
+	if err == ErrAccountNotFound || (err == nil && !account.checkPassword(password)) {
+		pipe := database.RedisClient.TxPipeline()
+		for _, key := range failureKeys {
+			pipe.Incr(key)
+			pipe.Expire(key, LoginBlockDuration)
+		}
+		pipe.Exec()
+		return "", errors.New("wrong username or password")
+	} else if err != nil {
+		return "", err
+	}
+	database.RedisClient.Del(failureKeys[0])
+	token, err := randomHex(32)
+	if err != nil {
+		return "", err
+	}
+	err = database.RedisClient.Set(sessionKey(token), account.Username, SessionDuration).Err()
+	return token, err
+}
+
+//Logout ends the session of the token
+func Logout(token string) error {
+	return database.RedisClient.Del(sessionKey(token)).Err()
+}
+
+//Authenticate loads the admin account of the bearer token of the request
+func Authenticate() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := bearerToken(c)
+		username, err := database.RedisClient.Get(sessionKey(token)).Result()
+		if token == "" || err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+				"message": "login required",
+			})
+			return
+		}
+		account, err := GetAccount(username)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+				"message": "login required",
+			})
+			return
+		}
+		c.Set(accountContextKey, account)
+		c.Next()
+	}
+}
+
+//RequireRole allows the request only for admins with the role or a higher role
+func RequireRole(role Role) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		account := currentAccount(c)
+		if account == nil || account.Role < role {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"message": "requires the " + role.String() + " role",
+			})
+			return
+		}
+		c.Next()
+	}
+}
+
+func currentAccount(c *gin.Context) *Account {
+	if value, ok := c.Get(accountContextKey); ok {
+		return value.(*Account)
+	}
+	return nil
+}
+
+func bearerToken(c *gin.Context) string {
+	header := c.GetHeader("Authorization")
+	if strings.HasPrefix(header, "Bearer ") {
+		return strings.TrimPrefix(header, "Bearer ")
+	}
+	return ""
+}
+
+func (a *Account) public() Account {
+	return Account{Username: a.Username, Role: a.Role, CreatedTimestamp: a.CreatedTimestamp}
+}
+
+func validatePassword(password string) error {
+	if len(password) < 8 || len(password) > maxPasswordLength {
+		return errors.New("the password needs 8 to 72 characters")
+	}
+	return nil
+}
+
+func (a *Account) setPassword(password string) error {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	a.PasswordHash = string(hash)
+	return nil
+}
+
+func (a *Account) checkPassword(password string) bool {
+	return bcrypt.CompareHashAndPassword([]byte(a.PasswordHash), []byte(password)) == nil
+}
+
+func saveAccount(account *Account) error {
+	accountJSON, err := json.Marshal(account)
+	if err != nil {
+		return err
+	}
+	return database.RedisClient.Set(accountKey(account.Username), string(accountJSON), 0).Err()
+}
+
+func randomHex(size int) (string, error) {
+	data := make([]byte, size)
+	if _, err := rand.Read(data); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(data), nil
+}
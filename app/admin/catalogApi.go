@@ -0,0 +1,138 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"sharequiz/app"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+//GetCatalog returns all the topics and languages including the disabled ones
+func GetCatalog(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"topics":    app.GetTopics(false),
+		"languages": app.GetLanguages(false),
+	})
+}
+
+//SaveTopic adds a topic, or updates the topic with the id. An update without
+//enabled keeps the topic enabled or disabled.
+func SaveTopic(c *gin.Context) {
+	topic := app.TopicInfo{}
+	body, err := c.GetRawData()
+	if err != nil || json.Unmarshal(body, &topic) != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "check the topic",
+		})
+		return
+	}
+	topic.ID = 0
+	if id := c.Param("id"); id != "" {
+		topicID, err := strconv.Atoi(id)
+		existing, ok := app.GetTopic(app.Topic(topicID))
+		if err != nil || !ok {
+			c.JSON(http.StatusNotFound, gin.H{
+				"message": "topic not found",
+			})
+			return
+		}
+		topic.ID = existing.ID
+		if topic.Images == nil {
+			topic.Images = existing.Images
+		}
+		if !hasField(body, "enabled") {
+			topic.Enabled = existing.Enabled
+		}
+	}
+	topic, err = app.SaveTopic(topic)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"topic": topic,
+	})
+}
+
+//UploadTopicImage stores the uploaded image field as the artwork of the topic
+func UploadTopicImage(c *gin.Context) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, app.MaxTopicImageBytes)
+	fileHeader, err := c.FormFile("image")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "check the image",
+		})
+		return
+	}
+	file, err := fileHeader.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "check the image",
+		})
+		return
+	}
+	defer file.Close()
+	topicID, _ := strconv.Atoi(c.Param("id"))
+	topic, err := app.SaveTopicImage(app.Topic(topicID), file)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"topic": topic,
+	})
+}
+
+//SaveLanguage adds a language, or updates the language with the id. An
+//update without enabled keeps the language enabled or disabled.
+func SaveLanguage(c *gin.Context) {
+	language := app.LanguageInfo{}
+	body, err := c.GetRawData()
+	if err != nil || json.Unmarshal(body, &language) != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "check the language",
+		})
+		return
+	}
+	language.ID = 0
+	if id := c.Param("id"); id != "" {
+		languageID, err := strconv.Atoi(id)
+		existing, ok := app.GetLanguage(app.Language(languageID))
+		if err != nil || !ok {
+			c.JSON(http.StatusNotFound, gin.H{
+				"message": "language not found",
+			})
+			return
+		}
+		language.ID = existing.ID
+		if !hasField(body, "enabled") {
+			language.Enabled = existing.Enabled
+		}
+	}
+	language, err = app.SaveLanguage(language)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"language": language,
+	})
+}
+
+//hasField checks if the json object has the field
+func hasField(body []byte, field string) bool {
+	fields := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return false
+	}
+	_, ok := fields[field]
+	return ok
+}
@@ -0,0 +1,29 @@
+package admin
+
+import (
+	"net/http"
+	"sharequiz/app"
+
+	"github.com/gin-gonic/gin"
+)
+
+//CurateDailyChallenge sets the questions of the daily challenge of a day and
+//language, only before any player started it
+func CurateDailyChallenge(c *gin.Context) {
+	data := app.CurateData{}
+	if err := c.ShouldBindJSON(&data); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "check the daily challenge",
+		})
+		return
+	}
+	if err := app.CurateDailyQuestions(data); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"message": "daily challenge saved",
+	})
+}
@@ -0,0 +1,65 @@
+package admin
+
+import (
+	"net/http"
+	"sharequiz/app"
+	"sharequiz/app/socket"
+
+	"github.com/gin-gonic/gin"
+)
+
+//GameActionData reason of an admin action on a game, with the player and the
+//score delta for score adjustments
+type GameActionData struct {
+	Reason   string `json:"reason"`
+	PlayerID string `json:"playerID"`
+	Delta    int    `json:"delta"`
+}
+
+//AdvanceGame moves the game to the next question
+func AdvanceGame(c *gin.Context) {
+	runGameAction(c, socket.ForceAdvanceQuestion)
+}
+
+//FinishGame finishes the game with the results computed so far
+func FinishGame(c *gin.Context) {
+	runGameAction(c, socket.ForceFinishGame)
+}
+
+//VoidGame excludes the game from the stats
+func VoidGame(c *gin.Context) {
+	runGameAction(c, socket.VoidGame)
+}
+
+//AdjustScore changes the score of a player in the game
+func AdjustScore(c *gin.Context) {
+	runGameAction(c, socket.AdjustScore)
+}
+
+func runGameAction(c *gin.Context, run func(string, app.GameAdminAction) (*app.Game, error)) {
+	data := GameActionData{}
+	if err := c.ShouldBindJSON(&data); err != nil || data.Reason == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "a reason is required",
+		})
+		return
+	}
+	action := app.GameAdminAction{
+		Reason:   data.Reason,
+		PlayerID: data.PlayerID,
+		Delta:    data.Delta,
+	}
+	if account := currentAccount(c); account != nil {
+		action.Admin = account.Username
+	}
+	game, err := run(c.Query("game_id"), action)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"game": game,
+	})
+}
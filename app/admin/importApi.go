@@ -0,0 +1,63 @@
+package admin
+
+import (
+	"io"
+	"net/http"
+	"sharequiz/app"
+
+	"github.com/gin-gonic/gin"
+)
+
+//ImportQuestions imports a csv or jsonl file of questions, uploaded as the
+//file form field or as the request body. dry_run only validates the file.
+func ImportQuestions(c *gin.Context) {
+	format := c.Query("format")
+	var reader io.Reader = c.Request.Body
+	if fileHeader, err := c.FormFile("file"); err == nil {
+		file, err := fileHeader.Open()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"message": "check the file",
+			})
+			return
+		}
+		defer file.Close()
+		reader = file
+		if format == "" {
+			format = app.FormatFromFileName(fileHeader.Filename)
+		}
+	}
+	if format == "" {
+		format = app.FormatJSONL
+	}
+	report, err := app.ImportQuestions(reader, format, c.Query("dry_run") == "true")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"report": report,
+	})
+}
+
+//ExportQuestions downloads the questions of the topic and language as csv or jsonl
+func ExportQuestions(c *gin.Context) {
+	format := c.DefaultQuery("format", app.FormatJSONL)
+	if format != app.FormatCSV && format != app.FormatJSONL {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "format should be csv or jsonl",
+		})
+		return
+	}
+	filter := app.QuestionFilter{
+		Topic:    app.Topic(queryInt(c, "topic", 0)),
+		Language: app.Language(queryInt(c, "language", 0)),
+	}
+	c.Header("Content-Disposition", "attachment; filename=questions."+format)
+	c.Status(http.StatusOK)
+	if err := app.ExportQuestions(c.Writer, format, filter); err != nil {
+		c.Error(err)
+	}
+}
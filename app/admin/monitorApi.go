@@ -0,0 +1,58 @@
+package admin
+
+import (
+	"io"
+	"net/http"
+	"sharequiz/app"
+	"sharequiz/app/database"
+	"sharequiz/app/socket"
+
+	"github.com/gin-gonic/gin"
+)
+
+//GetActiveGames lists the active games with their players and progress
+func GetActiveGames(c *gin.Context) {
+	games, err := app.GetActiveGames()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "error while getting active games",
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"games": games,
+	})
+}
+
+//GetWaitingPlayers lists the players waiting in each matchmaking queue
+func GetWaitingPlayers(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"queues": socket.WaitingQueues(),
+	})
+}
+
+//StreamGameEvents streams the game lifecycle events as server sent events
+func StreamGameEvents(c *gin.Context) {
+	pubsub := database.RedisClient.Subscribe(app.GameEventsChannel)
+	defer pubsub.Close()
+	if _, err := pubsub.Receive(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "error while subscribing to game events",
+		})
+		return
+	}
+	messages := pubsub.Channel()
+	done := c.Request.Context().Done()
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case message, ok := <-messages:
+			if !ok {
+				return false
+			}
+			c.SSEvent("game", message.Payload)
+			return true
+		case <-done:
+			return false
+		}
+	})
+}
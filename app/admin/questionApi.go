@@ -0,0 +1,199 @@
+package admin
+
+import (
+	"net/http"
+	"sharequiz/app"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+	//maxSearchResults elastic search does not page beyond its max_result_window
+	maxSearchResults = 10000
+)
+
+//GetQuestions searches the questions by text, topic, language, difficulty and state
+func GetQuestions(c *gin.Context) {
+	from, size := pageQuery(c)
+	if from+size > maxSearchResults {
+		size = maxSearchResults - from
+		if size <= 0 {
+			from, size = maxSearchResults, 0
+		}
+	}
+	search := app.QuestionSearch{
+		Text: c.Query("text"),
+		From: from,
+		Size: size,
+	}
+	search.Topic = app.Topic(queryInt(c, "topic", 0))
+	search.Language = app.Language(queryInt(c, "language", 0))
+	search.Difficulty = app.Difficulty(queryInt(c, "difficulty", 0))
+	search.State = app.QuestionState(queryInt(c, "state", 0))
+	questions, total, err := app.QuestionRepo.SearchQuestions(search)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "error while searching questions",
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"questions": questions,
+		"total":     total,
+	})
+}
+
+//GetQuestion returns the question with the id
+func GetQuestion(c *gin.Context) {
+	question, err := app.QuestionRepo.GetQuestion(c.Param("id"))
+	if err != nil {
+		sendQuestionError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"question": question,
+	})
+}
+
+//CreateQuestion validates and stores a new question
+func CreateQuestion(c *gin.Context) {
+	question := app.Question{}
+	if err := c.ShouldBindJSON(&question); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "check the question",
+		})
+		return
+	}
+	if err := app.ValidateQuestion(question); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+	question, err := app.QuestionRepo.CreateQuestion(question)
+	if err != nil {
+		sendQuestionError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"question": question,
+	})
+}
+
+//UpdateQuestion validates and replaces the question with the id, fields
+//missing in the request are cleared except the state which keeps its
+//moderation state
+func UpdateQuestion(c *gin.Context) {
+	question := app.Question{}
+	if err := c.ShouldBindJSON(&question); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "check the question",
+		})
+		return
+	}
+	stored, err := app.QuestionRepo.GetQuestion(c.Param("id"))
+	if err != nil {
+		sendQuestionError(c, err)
+		return
+	}
+	question.ID = stored.ID
+	if question.State == 0 {
+		question.State = stored.State
+	}
+	if err := app.ValidateQuestion(question); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+	question, err = app.QuestionRepo.UpdateQuestion(question)
+	if err != nil {
+		sendQuestionError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"question": question,
+	})
+}
+
+//DeleteQuestion deletes the question with the id
+func DeleteQuestion(c *gin.Context) {
+	if err := app.QuestionRepo.DeleteQuestion(c.Param("id")); err != nil {
+		sendQuestionError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"result": "success",
+	})
+}
+
+func sendQuestionError(c *gin.Context, err error) {
+	if err == app.ErrQuestionNotFound {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{
+		"message": "error while accessing the question",
+	})
+}
+
+//pageQuery reads the from and size query parameters limited to maxPageSize
+func pageQuery(c *gin.Context) (int, int) {
+	from := queryInt(c, "from", 0)
+	if from < 0 {
+		from = 0
+	}
+	size := queryInt(c, "size", defaultPageSize)
+	if size < 1 {
+		size = defaultPageSize
+	} else if size > maxPageSize {
+		size = maxPageSize
+	}
+	return from, size
+}
+
+func queryInt(c *gin.Context, key string, defaultValue int) int {
+	value, err := strconv.Atoi(c.Query(key))
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
+
+//SetExplanations replaces the explanations of the question, keyed by language.
+//Languages missing from the request lose their explanation and an empty
+//object clears them all, the whole question document is written again.
+func SetExplanations(c *gin.Context) {
+	explanations := make(map[string]app.Explanation)
+	if err := c.ShouldBindJSON(&explanations); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "check the explanations",
+		})
+		return
+	}
+	question, err := app.QuestionRepo.GetQuestion(c.Param("id"))
+	if err != nil {
+		sendQuestionError(c, err)
+		return
+	}
+	question.Explanations = explanations
+	if err := app.ValidateQuestion(question); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+	question, err = app.QuestionRepo.UpdateQuestion(question)
+	if err != nil {
+		sendQuestionError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"question": question,
+	})
+}
@@ -0,0 +1,98 @@
+package admin
+
+import (
+	"net/http"
+	"sharequiz/app"
+
+	"github.com/gin-gonic/gin"
+)
+
+//ReportAction triage of a question report, question is the corrected question for fix
+type ReportAction struct {
+	Action     string        `json:"action"`
+	Resolution string        `json:"resolution"`
+	Question   *app.Question `json:"question"`
+}
+
+//QuestionStateChange new state of a question
+type QuestionStateChange struct {
+	State app.QuestionState `json:"state"`
+}
+
+//GetReports returns the open question reports, or all with status=all
+func GetReports(c *gin.Context) {
+	reports, err := app.GetReports(c.Query("status") != "all")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "error while getting reports",
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"reports": reports,
+	})
+}
+
+//ResolveReport dismisses the report, or retires or fixes the reported question
+func ResolveReport(c *gin.Context) {
+	action := ReportAction{}
+	if err := c.ShouldBindJSON(&action); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "check the action",
+		})
+		return
+	}
+	id := c.Param("id")
+	var err error
+	switch action.Action {
+	case "dismiss":
+		err = app.DismissReport(id, action.Resolution)
+	case "retire":
+		err = app.RetireReportedQuestion(id, action.Resolution)
+	case "fix":
+		if action.Question == nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"message": "the corrected question is required",
+			})
+			return
+		}
+		err = app.FixReportedQuestion(id, *action.Question, action.Resolution)
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "action should be dismiss, retire or fix",
+		})
+		return
+	}
+	if err == app.ErrReportNotFound || err == app.ErrQuestionNotFound {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": err.Error(),
+		})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"result": "success",
+	})
+}
+
+//SetQuestionState moves the question to another moderation state
+func SetQuestionState(c *gin.Context) {
+	change := QuestionStateChange{}
+	if err := c.ShouldBindJSON(&change); err != nil || change.State < app.Draft || change.State > app.Retired {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "check the state",
+		})
+		return
+	}
+	if err := app.QuestionRepo.SetState(c.Param("id"), change.State); err != nil {
+		sendQuestionError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"result": "success",
+	})
+}
@@ -0,0 +1,29 @@
+package admin
+
+import (
+	"net/http"
+	"sharequiz/app"
+
+	"github.com/gin-gonic/gin"
+)
+
+//CreateTournament schedules a tournament
+func CreateTournament(c *gin.Context) {
+	data := app.TournamentData{}
+	if err := c.ShouldBindJSON(&data); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "check the tournament",
+		})
+		return
+	}
+	tournament, err := app.CreateTournament(data)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"tournament": tournament,
+	})
+}
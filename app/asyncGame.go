@@ -0,0 +1,524 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sharequiz/app/database"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis"
+)
+
+//Game modes, live games are played over the socket by both players at once
+const (
+	ModeLive  = ""
+	ModeAsync = "async"
+	ModeDaily = "daily"
+)
+
+const (
+	//AsyncGameDuration time both players have to play an async game
+	AsyncGameDuration = 24 * time.Hour
+
+	//NotificationAsyncChallenge player was challenged to an async game
+	NotificationAsyncChallenge = "async_challenge"
+	//NotificationAsyncResult async game finished
+	NotificationAsyncResult = "async_result"
+
+	asyncDeadlinesKey = "async-game-deadlines"
+)
+
+//Errors of the async games
+var (
+	ErrGameNotFound    = errors.New("game not found")
+	ErrNotAsyncGame    = errors.New("not an async game")
+	ErrAsyncGameOver   = errors.New("game is over")
+	ErrWrongQuestion   = errors.New("answer the current question")
+	ErrPlayerNotInGame = errors.New("player not in the game")
+)
+
+const (
+	//asyncLockDuration time after which the lock of a crashed request expires
+	asyncLockDuration = 30 * time.Second
+	//asyncLockWait time a request waits for the lock of the game
+	asyncLockWait = 5 * time.Second
+)
+
+//ErrGameBusy returned when the lock of the game could not be taken in time
+var ErrGameBusy = errors.New("game is busy, try again")
+
+//unlockScript deletes the lock only while it is still held by the token
+var unlockScript = redis.NewScript(`if redis.call("get", KEYS[1]) == ARGV[1] then
+	return redis.call("del", KEYS[1])
+end
+return 0`)
+
+func asyncGameLockKey(gameID string) string {
+	return "async-game-lock-" + gameID
+}
+
+//lockAsyncGame takes the lock of the async or daily game in redis, so that
+//the requests of a game are serialized across the servers while different
+//games run in parallel. The returned function releases the lock.
+func lockAsyncGame(gameID string) (func(), error) {
+	token, err := randomHex(16)
+	if err != nil {
+		return nil, err
+	}
+	key := asyncGameLockKey(gameID)
+	deadline := time.Now().Add(asyncLockWait)
+	for {
+		locked, err := database.RedisClient.SetNX(key, token, asyncLockDuration).Result()
+		if err != nil {
+			return nil, err
+		}
+		if locked {
+			return func() {
+				unlockScript.Run(database.RedisClient, []string{key}, token)
+			}, nil
+		}
+		if time.Now().After(deadline) {
+			return nil, ErrGameBusy
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+//AsyncGameData sent to challenge a player to an async game
+type AsyncGameData struct {
+	OpponentID string   `json:"opponentID"`
+	Topic      Topic    `json:"topic,string"`
+	Language   Language `json:"language,string"`
+}
+
+//AsyncAnswerData answer of the player to the current question
+type AsyncAnswerData struct {
+	QuestionNumber int    `json:"questionNumber"`
+	Answer         string `json:"answer"`
+}
+
+//AsyncQuestion question shown to the player without the answer
+type AsyncQuestion struct {
+	Number       int          `json:"number"`
+	Type         QuestionType `json:"type,omitempty"`
+	QuestionText string       `json:"questionText"`
+	ImageURL     string       `json:"imageURL,omitempty"`
+	Options      []string     `json:"options"`
+	TimeLimit    int64        `json:"timeLimit"`
+}
+
+//AsyncAnswerResult result of an answer of an async game
+type AsyncAnswerResult struct {
+	Correct     bool         `json:"correct"`
+	Answer      string       `json:"answer"`
+	Explanation *Explanation `json:"explanation,omitempty"`
+	Score       int          `json:"score"`
+	Finished    bool         `json:"finished"`
+}
+
+//AsyncGameSummary async game as seen by one of the players
+type AsyncGameSummary struct {
+	GameID    string         `json:"gameID"`
+	Topic     Topic          `json:"topic,string"`
+	Language  Language       `json:"language,string"`
+	Opponent  PublicProfile  `json:"opponent"`
+	Progress  int            `json:"progress"`
+	Questions int            `json:"questions"`
+	Deadline  int64          `json:"deadline"`
+	Status    Status         `json:"status,string"`
+	Winner    string         `json:"winner,omitempty"`
+	Results   []PlayerResult `json:"results,omitempty"`
+}
+
+func asyncGamesKey(userID string) string {
+	return "async-games-" + userID
+}
+
+//CreateAsyncGame creates a game with a fixed question set for the players to
+//play at their own pace before the deadline
+func CreateAsyncGame(userID string, data AsyncGameData) (*Game, error) {
+	if data.OpponentID == userID || !UserExists(data.OpponentID) {
+		return nil, ErrUnknownPlayer
+	}
+	if !TopicEnabled(data.Topic) || !LanguageEnabled(data.Language) {
+		return nil, errors.New("check the topic and language")
+	}
+	playerIDs := []string{userID, data.OpponentID}
+	gameID, err := CreateGame(NumOfQuestionsInGame, data.Language, len(playerIDs), data.Topic, playerIDs)
+	if err != nil {
+		return nil, err
+	}
+	RemoveActiveGame(gameID)
+	game, err := GetGame(gameID)
+	if err != nil {
+		return nil, err
+	}
+	game.Mode = ModeAsync
+	game.Deadline = time.Now().Add(AsyncGameDuration).Unix()
+	game.Progress = make(map[string]int)
+	game.PlayerShownAt = make(map[string]int64)
+	for _, playerID := range playerIDs {
+		profile, err := GetPublicProfile(playerID)
+		if err != nil {
+			return nil, err
+		}
+		game.Players[playerID] = Player{ID: playerID, Name: profile.DisplayName, Avatar: profile.Avatar}
+		game.Scores[playerID] = make([]int, game.MaxQuestions+1)
+		game.Progress[playerID] = 0
+	}
+	if _, err := SaveGame(game); err != nil {
+		return nil, err
+	}
+	pipe := database.RedisClient.Pipeline()
+	for _, playerID := range playerIDs {
+		pipe.ZAdd(asyncGamesKey(playerID), redis.Z{Score: float64(game.CreatedTimestamp), Member: game.ID})
+	}
+	pipe.ZAdd(asyncDeadlinesKey, redis.Z{Score: float64(game.Deadline), Member: game.ID})
+	if _, err := pipe.Exec(); err != nil {
+		return nil, err
+	}
+	Notify(data.OpponentID, NotificationAsyncChallenge, userID, map[string]string{
+		"gameID": game.ID,
+	})
+	return game, nil
+}
+
+func getAsyncGame(gameID string, userID string) (*Game, error) {
+	game, err := GetGame(gameID)
+	if err == redis.Nil {
+		return nil, ErrGameNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	if game.Mode != ModeAsync && game.Mode != ModeDaily {
+		return nil, ErrNotAsyncGame
+	}
+	if _, ok := game.Players[userID]; !ok {
+		return nil, ErrPlayerNotInGame
+	}
+	return game, nil
+}
+
+//NextAsyncQuestion returns the next question of the player, the answer time
+//starts when it is shown the first time
+func NextAsyncQuestion(gameID string, userID string) (*AsyncQuestion, error) {
+	unlock, err := lockAsyncGame(gameID)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	game, err := getAsyncGame(gameID, userID)
+	if err != nil {
+		return nil, err
+	}
+	number := game.Progress[userID] + 1
+	if asyncGameOver(game, number) {
+		return nil, ErrAsyncGameOver
+	}
+	if _, ok := game.PlayerShownAt[userID]; !ok {
+		game.PlayerShownAt[userID] = time.Now().UnixNano() / int64(time.Millisecond)
+		if _, err := SaveGame(game); err != nil {
+			return nil, err
+		}
+		PublishGameEvent(GameEvent{Type: EventQuestionShown, GameID: game.ID, PlayerID: userID, QuestionNumber: number})
+	}
+	question := game.Questions[number]
+	return &AsyncQuestion{
+		Number:       number,
+		Type:         question.Type,
+		QuestionText: question.QuestionText,
+		ImageURL:     question.ImageURL,
+		Options:      question.Options,
+		TimeLimit:    AnswerTime,
+	}, nil
+}
+
+//AnswerAsyncQuestion grades the answer of the player to the current question,
+//the game finishes once both players answered every question
+func AnswerAsyncQuestion(gameID string, userID string, data AsyncAnswerData) (*AsyncAnswerResult, error) {
+	unlock, err := lockAsyncGame(gameID)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	game, err := getAsyncGame(gameID, userID)
+	if err != nil {
+		return nil, err
+	}
+	number := game.Progress[userID] + 1
+	if asyncGameOver(game, number) {
+		return nil, ErrAsyncGameOver
+	}
+	shownAt, ok := game.PlayerShownAt[userID]
+	if data.QuestionNumber != number || !ok {
+		return nil, ErrWrongQuestion
+	}
+	question := &game.Questions[number]
+	taken := time.Now().UnixNano()/int64(time.Millisecond) - shownAt
+	if question.PlayerAnswers == nil {
+		question.PlayerAnswers = make(map[string]string)
+	}
+	if question.AnswerTimes == nil {
+		question.AnswerTimes = make(map[string]int64)
+	}
+	question.PlayerAnswers[userID] = data.Answer
+	question.AnswerTimes[userID] = taken
+	result := &AsyncAnswerResult{
+		Correct:     question.IsCorrect(data.Answer),
+		Answer:      question.Answer,
+		Explanation: question.ExplanationFor(game.Language),
+	}
+	if result.Correct {
+		result.Score = AnswerScore(taken, answerTimeLimit(game, userID, number))
+	}
+	game.Scores[userID][number] = result.Score
+	PublishGameEvent(GameEvent{Type: EventAnswer, GameID: game.ID, PlayerID: userID, QuestionNumber: number, Data: data.Answer})
+	result.Finished = number == game.MaxQuestions
+	return result, nextAsyncQuestion(game, userID, number)
+}
+
+//asyncGameOver tells if the player can no longer play the question, the
+//game is finished or its deadline passed
+func asyncGameOver(game *Game, number int) bool {
+	if game.Status != Active || number > game.MaxQuestions {
+		return true
+	}
+	return game.Deadline > 0 && time.Now().Unix() >= game.Deadline
+}
+
+//nextAsyncQuestion moves the player past the question and finishes the game
+//once every player is done
+func nextAsyncQuestion(game *Game, userID string, number int) error {
+	game.Progress[userID] = number
+	delete(game.PlayerShownAt, userID)
+	for playerID := range game.Players {
+		if game.Progress[playerID] < game.MaxQuestions {
+			_, err := SaveGame(game)
+			return err
+		}
+	}
+	return finishAsyncGame(game)
+}
+
+//UseAsyncLifeline applies the lifeline of the player to the shown question,
+//a skip moves the player to the next question
+func UseAsyncLifeline(gameID string, userID string, lifeline string) (*LifelineUse, error) {
+	unlock, err := lockAsyncGame(gameID)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	game, err := getAsyncGame(gameID, userID)
+	if err != nil {
+		return nil, err
+	}
+	number := game.Progress[userID] + 1
+	if asyncGameOver(game, number) {
+		return nil, ErrAsyncGameOver
+	}
+	if _, ok := game.PlayerShownAt[userID]; !ok {
+		return nil, ErrWrongQuestion
+	}
+	use, err := UseLifeline(game, userID, lifeline, number)
+	if err != nil {
+		return nil, err
+	}
+	PublishGameEvent(GameEvent{Type: EventLifeline, GameID: game.ID, PlayerID: userID, QuestionNumber: number, Data: lifeline})
+	if lifeline == LifelineSkip {
+		return use, nextAsyncQuestion(game, userID, number)
+	}
+	_, err = SaveGame(game)
+	return use, err
+}
+
+func finishAsyncGame(game *Game) error {
+	game.QuestionNumber = game.MaxQuestions
+	FinishGame(game)
+	if _, err := SaveGame(game); err != nil {
+		return err
+	}
+	database.RedisClient.ZRem(asyncDeadlinesKey, game.ID)
+	PublishGameEvent(GameEvent{Type: EventGameOver, GameID: game.ID, QuestionNumber: game.QuestionNumber})
+	GameFinished(game)
+	if game.Mode != ModeAsync {
+		return nil
+	}
+	for playerID := range game.Players {
+		Notify(playerID, NotificationAsyncResult, "", map[string]string{
+			"gameID": game.ID,
+			"winner": game.Winner,
+		})
+	}
+	return nil
+}
+
+//FinishExpiredAsyncGames finishes the async games past their deadline, the
+//questions a player did not answer count as wrong
+func FinishExpiredAsyncGames() {
+	gameIDs, err := database.RedisClient.ZRangeByScore(asyncDeadlinesKey, redis.ZRangeBy{
+		Min: "-inf",
+		Max: fmt.Sprint(time.Now().Unix()),
+	}).Result()
+	if err != nil {
+		return
+	}
+	for _, gameID := range gameIDs {
+		unlock, err := lockAsyncGame(gameID)
+		if err != nil {
+			continue
+		}
+		game, err := GetGame(gameID)
+		if err != nil || game.Status != Active {
+			database.RedisClient.ZRem(asyncDeadlinesKey, gameID)
+		} else if err := finishAsyncGame(game); err != nil {
+			fmt.Println("error while finishing the async game " + gameID)
+		}
+		unlock()
+	}
+}
+
+//StartAsyncGameExpiry finishes the expired async games at every interval
+func StartAsyncGameExpiry(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		FinishExpiredAsyncGames()
+	}
+}
+
+//GetAsyncGames returns the async games of the player, newest first
+func GetAsyncGames(userID string) ([]AsyncGameSummary, error) {
+	gameIDs, err := database.RedisClient.ZRevRange(asyncGamesKey(userID), 0, -1).Result()
+	if err != nil {
+		return nil, err
+	}
+	summaries := make([]AsyncGameSummary, 0, len(gameIDs))
+	for _, gameID := range gameIDs {
+		game, err := GetGame(gameID)
+		if err != nil {
+			continue
+		}
+		summary := AsyncGameSummary{
+			GameID:    game.ID,
+			Topic:     game.Topic,
+			Language:  game.Language,
+			Progress:  game.Progress[userID],
+			Questions: game.MaxQuestions,
+			Deadline:  game.Deadline,
+			Status:    game.Status,
+			Winner:    game.Winner,
+			Results:   game.Results,
+		}
+		for playerID, player := range game.Players {
+			if playerID != userID {
+				summary.Opponent = PublicProfile{UserID: playerID, DisplayName: player.Name, Avatar: player.Avatar}
+			}
+		}
+		summaries = append(summaries, summary)
+	}
+	return summaries, nil
+}
+
+//CreateAsyncGameHandler challenges a player to an async game
+func CreateAsyncGameHandler(c *gin.Context) {
+	data := AsyncGameData{}
+	if err := c.ShouldBindJSON(&data); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "check the game",
+		})
+		return
+	}
+	game, err := CreateAsyncGame(CurrentUserID(c), data)
+	if err != nil {
+		sendAsyncError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"gameID":   game.ID,
+		"deadline": game.Deadline,
+	})
+}
+
+//GetAsyncGamesHandler returns the async games of the logged in player
+func GetAsyncGamesHandler(c *gin.Context) {
+	games, err := GetAsyncGames(CurrentUserID(c))
+	if err != nil {
+		sendError(c, "error while getting the games")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"games": games,
+	})
+}
+
+//UseAsyncLifelineHandler uses a lifeline on the current question of the logged in player
+func UseAsyncLifelineHandler(c *gin.Context) {
+	data := LifelineData{}
+	if err := c.ShouldBindJSON(&data); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "check the lifeline",
+		})
+		return
+	}
+	use, err := UseAsyncLifeline(c.Param("id"), CurrentUserID(c), data.Lifeline)
+	if err != nil {
+		sendAsyncError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"lifeline": use,
+	})
+}
+
+//GetAsyncQuestion returns the current question of the logged in player
+func GetAsyncQuestion(c *gin.Context) {
+	question, err := NextAsyncQuestion(c.Param("id"), CurrentUserID(c))
+	if err != nil {
+		sendAsyncError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"question": question,
+	})
+}
+
+//AnswerAsyncQuestionHandler answers the current question of the logged in player
+func AnswerAsyncQuestionHandler(c *gin.Context) {
+	data := AsyncAnswerData{}
+	if err := c.ShouldBindJSON(&data); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "check the answer",
+		})
+		return
+	}
+	result, err := AnswerAsyncQuestion(c.Param("id"), CurrentUserID(c), data)
+	if err != nil {
+		sendAsyncError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"result": result,
+	})
+}
+
+func sendAsyncError(c *gin.Context, err error) {
+	switch err {
+	case ErrGameBusy:
+		c.JSON(http.StatusConflict, gin.H{
+			"message": err.Error(),
+		})
+	case ErrGameNotFound, ErrUnknownPlayer:
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": err.Error(),
+		})
+	case ErrNotAsyncGame, ErrAsyncGameOver, ErrWrongQuestion, ErrPlayerNotInGame, ErrNotEnoughQuestions,
+		ErrUnknownLifeline, ErrLifelineUsedUp, ErrLifelineQuestion:
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+	default:
+		sendError(c, err.Error())
+	}
+}
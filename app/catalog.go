@@ -0,0 +1,428 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"regexp"
+	"sharequiz/app/database"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	catalogTopicsKey         = "catalog-topics"
+	catalogLanguagesKey      = "catalog-languages"
+	catalogLastTopicIDKey    = "catalog-last-topic-id"
+	catalogLastLanguageIDKey = "catalog-last-language-id"
+)
+
+//catalogKeyPattern keys of the topics and languages
+var catalogKeyPattern = regexp.MustCompile(`^[a-z0-9_-]+$`)
+
+//TopicInfo catalog entry of a topic. Key identifies the topic in the stored
+//questions and never changes, Name can be renamed and display names are keyed
+//by the language key.
+type TopicInfo struct {
+	ID            Topic             `json:"id"`
+	Key           string            `json:"key"`
+	Name          string            `json:"name"`
+	DisplayNames  map[string]string `json:"displayNames"`
+	Icon          string            `json:"icon"`
+	Enabled       bool              `json:"enabled"`
+	SortOrder     int               `json:"sortOrder"`
+	RelatedTopics []Topic           `json:"relatedTopics"`
+	Images        []ImageVariant    `json:"images,omitempty"`
+}
+
+//LanguageInfo catalog entry of a language, the key identifies the language in
+//the stored questions and explanations and never changes
+type LanguageInfo struct {
+	ID           Language          `json:"id"`
+	Key          string            `json:"key"`
+	Name         string            `json:"name"`
+	DisplayNames map[string]string `json:"displayNames"`
+	Icon         string            `json:"icon"`
+	Enabled      bool              `json:"enabled"`
+	SortOrder    int               `json:"sortOrder"`
+}
+
+//catalog cached copy of the topics and languages stored in redis
+var catalog = struct {
+	sync.RWMutex
+	topics    map[Topic]TopicInfo
+	languages map[Language]LanguageInfo
+}{
+	topics:    defaultTopics(),
+	languages: defaultLanguages(),
+}
+
+func defaultTopics() map[Topic]TopicInfo {
+	topics := map[Topic]TopicInfo{
+		India:      {Name: "India", RelatedTopics: []Topic{World}},
+		Science:    {Name: "Science", RelatedTopics: []Topic{Technology}},
+		Technology: {Name: "Technology", RelatedTopics: []Topic{Science}},
+		World:      {Name: "World", RelatedTopics: []Topic{India}},
+	}
+	for id, topic := range topics {
+		topic.ID = id
+		topic.Key = strings.ToLower(topic.Name)
+		topic.DisplayNames = map[string]string{"english": topic.Name}
+		topic.Icon = "/static/images/" + strings.ToLower(topic.Name) + ".png"
+		topic.Enabled = true
+		topic.SortOrder = int(id)
+		topics[id] = topic
+	}
+	return topics
+}
+
+func defaultLanguages() map[Language]LanguageInfo {
+	languages := map[Language]LanguageInfo{
+		English: {Name: "English"},
+		Hindi:   {Name: "Hindi", DisplayNames: map[string]string{"hindi": "हिन्दी"}},
+		Bengali: {Name: "Bengali", DisplayNames: map[string]string{"bengali": "বাংলা"}},
+		Tamil:   {Name: "Tamil", DisplayNames: map[string]string{"tamil": "தமிழ்"}},
+		Odia:    {Name: "Odia", DisplayNames: map[string]string{"odia": "ଓଡ଼ିଆ"}},
+	}
+	for id, language := range languages {
+		language.ID = id
+		language.Key = strings.ToLower(language.Name)
+		if language.DisplayNames == nil {
+			language.DisplayNames = make(map[string]string)
+		}
+		language.DisplayNames["english"] = language.Name
+		language.Enabled = true
+		language.SortOrder = int(id)
+		languages[id] = language
+	}
+	return languages
+}
+
+//InitCatalog loads the catalog, storing the default topics and languages the
+//first time
+func InitCatalog() {
+	for id, topic := range defaultTopics() {
+		if err := hSetNXJSON(catalogTopicsKey, strconv.Itoa(int(id)), topic); err != nil {
+			log.Println("error while storing default topics", err)
+		}
+	}
+	for id, language := range defaultLanguages() {
+		if err := hSetNXJSON(catalogLanguagesKey, strconv.Itoa(int(id)), language); err != nil {
+			log.Println("error while storing default languages", err)
+		}
+	}
+	if err := LoadCatalog(); err != nil {
+		log.Println("error while loading the catalog", err)
+	}
+}
+
+//StartCatalogRefresh reloads the catalog at every interval to pick up changes
+//made on other servers
+func StartCatalogRefresh(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		if err := LoadCatalog(); err != nil {
+			log.Println("error while loading the catalog", err)
+		}
+	}
+}
+
+//LoadCatalog loads the topics and languages from redis into the cache
+func LoadCatalog() error {
+	topicValues, err := database.RedisClient.HVals(catalogTopicsKey).Result()
+	if err != nil {
+		return err
+	}
+	languageValues, err := database.RedisClient.HVals(catalogLanguagesKey).Result()
+	if err != nil {
+		return err
+	}
+	topics := make(map[Topic]TopicInfo)
+	for _, value := range topicValues {
+		topic := TopicInfo{}
+		if err := json.Unmarshal([]byte(value), &topic); err == nil {
+			//topics stored before the keys were keyed by their name
+			if topic.Key == "" {
+				topic.Key = strings.ToLower(topic.Name)
+			}
+			topics[topic.ID] = topic
+		}
+	}
+	languages := make(map[Language]LanguageInfo)
+	for _, value := range languageValues {
+		language := LanguageInfo{}
+		if err := json.Unmarshal([]byte(value), &language); err == nil {
+			if language.Key == "" {
+				language.Key = strings.ToLower(language.Name)
+			}
+			languages[language.ID] = language
+		}
+	}
+	catalog.Lock()
+	catalog.topics = topics
+	catalog.languages = languages
+	catalog.Unlock()
+	return nil
+}
+
+//GetCatalog returns the enabled topics and languages for the clients, with
+//the topic image urls for the density of the screen
+func GetCatalog(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"topics":    topicResponses(GetTopics(true), parseDensity(c.Query("density"))),
+		"languages": GetLanguages(true),
+	})
+}
+
+//GetTopic returns the catalog entry of the topic
+func GetTopic(topic Topic) (TopicInfo, bool) {
+	catalog.RLock()
+	defer catalog.RUnlock()
+	info, ok := catalog.topics[topic]
+	return info, ok
+}
+
+//GetLanguage returns the catalog entry of the language
+func GetLanguage(language Language) (LanguageInfo, bool) {
+	catalog.RLock()
+	defer catalog.RUnlock()
+	info, ok := catalog.languages[language]
+	return info, ok
+}
+
+//TopicEnabled whether the topic can be played
+func TopicEnabled(topic Topic) bool {
+	info, ok := GetTopic(topic)
+	return ok && info.Enabled
+}
+
+//LanguageEnabled whether the language can be played
+func LanguageEnabled(language Language) bool {
+	info, ok := GetLanguage(language)
+	return ok && info.Enabled
+}
+
+//GetTopics returns the topics of the catalog in their sort order
+func GetTopics(onlyEnabled bool) []TopicInfo {
+	catalog.RLock()
+	topics := make([]TopicInfo, 0, len(catalog.topics))
+	for _, topic := range catalog.topics {
+		if topic.Enabled || !onlyEnabled {
+			topics = append(topics, topic)
+		}
+	}
+	catalog.RUnlock()
+	sort.Slice(topics, func(i, j int) bool {
+		if topics[i].SortOrder != topics[j].SortOrder {
+			return topics[i].SortOrder < topics[j].SortOrder
+		}
+		return topics[i].ID < topics[j].ID
+	})
+	return topics
+}
+
+//GetLanguages returns the languages of the catalog in their sort order
+func GetLanguages(onlyEnabled bool) []LanguageInfo {
+	catalog.RLock()
+	languages := make([]LanguageInfo, 0, len(catalog.languages))
+	for _, language := range catalog.languages {
+		if language.Enabled || !onlyEnabled {
+			languages = append(languages, language)
+		}
+	}
+	catalog.RUnlock()
+	sort.Slice(languages, func(i, j int) bool {
+		if languages[i].SortOrder != languages[j].SortOrder {
+			return languages[i].SortOrder < languages[j].SortOrder
+		}
+		return languages[i].ID < languages[j].ID
+	})
+	return languages
+}
+
+//ParseLanguage returns the language with the key or the name ignoring the case
+func ParseLanguage(name string) (Language, bool) {
+	languages := GetLanguages(false)
+	for _, language := range languages {
+		if strings.EqualFold(language.Key, name) {
+			return language.ID, true
+		}
+	}
+	for _, language := range languages {
+		if strings.EqualFold(language.Name, name) {
+			return language.ID, true
+		}
+	}
+	return 0, false
+}
+
+//ParseTopic returns the topic with the key or the name ignoring the case
+func ParseTopic(name string) (Topic, bool) {
+	topics := GetTopics(false)
+	for _, topic := range topics {
+		if strings.EqualFold(topic.Key, name) {
+			return topic.ID, true
+		}
+	}
+	for _, topic := range topics {
+		if strings.EqualFold(topic.Name, name) {
+			return topic.ID, true
+		}
+	}
+	return 0, false
+}
+
+//SaveTopic adds or updates a topic of the catalog. A new topic gets the next
+//id and its key, the key of an existing topic does not change.
+func SaveTopic(topic TopicInfo) (TopicInfo, error) {
+	topic.Name = strings.TrimSpace(topic.Name)
+	if topic.Name == "" {
+		return TopicInfo{}, errors.New("topic name is required")
+	}
+	if existing, ok := ParseTopic(topic.Name); ok && existing != topic.ID {
+		return TopicInfo{}, errors.New("topic name already in use")
+	}
+	if err := validateRelatedTopics(topic); err != nil {
+		return TopicInfo{}, err
+	}
+	if topic.ID != 0 {
+		existing, ok := GetTopic(topic.ID)
+		if !ok {
+			return TopicInfo{}, errors.New("topic not found")
+		}
+		topic.Key = existing.Key
+		if err := hSetJSON(catalogTopicsKey, strconv.Itoa(int(topic.ID)), topic); err != nil {
+			return TopicInfo{}, err
+		}
+		return topic, LoadCatalog()
+	}
+	key, err := newCatalogKey(topic.Key, topic.Name)
+	if err != nil {
+		return TopicInfo{}, err
+	}
+	if _, ok := ParseTopic(key); ok {
+		return TopicInfo{}, errors.New("topic key already in use")
+	}
+	topic.Key = key
+	id, err := addCatalogEntry(catalogTopicsKey, catalogLastTopicIDKey, func(id int64) interface{} {
+		topic.ID = Topic(id)
+		return topic
+	})
+	if err != nil {
+		return TopicInfo{}, err
+	}
+	topic.ID = Topic(id)
+	return topic, LoadCatalog()
+}
+
+//SaveLanguage adds or updates a language of the catalog. A new language gets
+//the next id and its key, the key of an existing language does not change.
+func SaveLanguage(language LanguageInfo) (LanguageInfo, error) {
+	language.Name = strings.TrimSpace(language.Name)
+	if language.Name == "" {
+		return LanguageInfo{}, errors.New("language name is required")
+	}
+	if existing, ok := ParseLanguage(language.Name); ok && existing != language.ID {
+		return LanguageInfo{}, errors.New("language name already in use")
+	}
+	if language.ID != 0 {
+		existing, ok := GetLanguage(language.ID)
+		if !ok {
+			return LanguageInfo{}, errors.New("language not found")
+		}
+		language.Key = existing.Key
+		if err := hSetJSON(catalogLanguagesKey, strconv.Itoa(int(language.ID)), language); err != nil {
+			return LanguageInfo{}, err
+		}
+		return language, LoadCatalog()
+	}
+	key, err := newCatalogKey(language.Key, language.Name)
+	if err != nil {
+		return LanguageInfo{}, err
+	}
+	if _, ok := ParseLanguage(key); ok {
+		return LanguageInfo{}, errors.New("language key already in use")
+	}
+	language.Key = key
+	id, err := addCatalogEntry(catalogLanguagesKey, catalogLastLanguageIDKey, func(id int64) interface{} {
+		language.ID = Language(id)
+		return language
+	})
+	if err != nil {
+		return LanguageInfo{}, err
+	}
+	language.ID = Language(id)
+	return language, LoadCatalog()
+}
+
+//validateRelatedTopics checks the related topics are other topics of the catalog
+func validateRelatedTopics(topic TopicInfo) error {
+	related := make(map[Topic]bool)
+	for _, id := range topic.RelatedTopics {
+		if _, ok := GetTopic(id); !ok {
+			return errors.New("unknown related topic " + strconv.Itoa(int(id)))
+		}
+		if id == topic.ID || related[id] {
+			return errors.New("related topics should be other topics without repeats")
+		}
+		related[id] = true
+	}
+	return nil
+}
+
+//newCatalogKey returns the requested key of a new entry, or the lower case name
+func newCatalogKey(key string, name string) (string, error) {
+	if key == "" {
+		key = strings.ToLower(strings.Join(strings.Fields(name), "-"))
+	}
+	if !catalogKeyPattern.MatchString(key) {
+		return "", errors.New("key should only have lower case letters, digits, - and _")
+	}
+	return key, nil
+}
+
+//addCatalogEntry stores the entry under the next unused id of the counter and
+//returns the id
+func addCatalogEntry(hashKey string, counterKey string, entry func(id int64) interface{}) (int64, error) {
+	for {
+		id, err := database.RedisClient.Incr(counterKey).Result()
+		if err != nil {
+			return 0, err
+		}
+		entryJSON, err := json.Marshal(entry(id))
+		if err != nil {
+			return 0, err
+		}
+		added, err := database.RedisClient.HSetNX(hashKey, strconv.FormatInt(id, 10), string(entryJSON)).Result()
+		if err != nil {
+			return 0, err
+		}
+		//the counter starts below the default entries
+		if added {
+			return id, nil
+		}
+	}
+}
+
+func hSetJSON(key string, field string, value interface{}) error {
+	valueJSON, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return database.RedisClient.HSet(key, field, string(valueJSON)).Err()
+}
+
+func hSetNXJSON(key string, field string, value interface{}) error {
+	valueJSON, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return database.RedisClient.HSetNX(key, field, string(valueJSON)).Err()
+}
@@ -0,0 +1,309 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"hash/fnv"
+	"net/http"
+	"sharequiz/app/database"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis"
+)
+
+//DailyChallenge leaderboard window of the daily challenge
+const DailyChallenge = "daily_challenge"
+
+//DailyChallengeRetention time the question set and the board of a day are kept
+const DailyChallengeRetention = 14 * 24 * time.Hour
+
+//Errors of the daily challenge
+var (
+	ErrDailyChallengePlayed = errors.New("daily challenge already played")
+	ErrDailyQuestionsSet    = errors.New("the questions of the day are already set")
+)
+
+//DailyChallengeStatus daily challenge of a language as seen by a player
+type DailyChallengeStatus struct {
+	Date      string   `json:"date"`
+	Language  Language `json:"language,string"`
+	Questions int      `json:"questions"`
+	GameID    string   `json:"gameID,omitempty"`
+	Finished  bool     `json:"finished"`
+	Score     int      `json:"score"`
+}
+
+//CurateData questions chosen by an admin for the daily challenge of a day
+type CurateData struct {
+	Date        string   `json:"date"`
+	Language    Language `json:"language,string"`
+	QuestionIDs []string `json:"questionIDs"`
+}
+
+func dailyQuestionsKey(date string, language Language) string {
+	return "daily-challenge-" + date + "-" + strconv.Itoa(int(language))
+}
+
+func dailyGamesKey(date string, language Language) string {
+	return "daily-challenge-games-" + date + "-" + strconv.Itoa(int(language))
+}
+
+//dailySeed seed of the question selection of the day and language
+func dailySeed(date string, language Language) int64 {
+	hash := fnv.New64a()
+	hash.Write([]byte(dailyQuestionsKey(date, language)))
+	return int64(hash.Sum64() >> 1)
+}
+
+//GetDailyQuestions returns the question set of the day, curated by an admin
+//or selected with the seed of the day the first time it is needed
+func GetDailyQuestions(date string, language Language) ([]Question, error) {
+	key := dailyQuestionsKey(date, language)
+	data, err := database.RedisClient.Get(key).Result()
+	if err == redis.Nil {
+		questions, err := GetSeededGameQuestions(0, language, NumOfQuestionsInGame, dailySeed(date, language))
+		if err != nil {
+			return nil, err
+		}
+		questionsJSON, err := json.Marshal(questions)
+		if err != nil {
+			return nil, err
+		}
+		database.RedisClient.SetNX(key, string(questionsJSON), DailyChallengeRetention)
+		data, err = database.RedisClient.Get(key).Result()
+		if err != nil {
+			return nil, err
+		}
+	} else if err != nil {
+		return nil, err
+	}
+	questions := make([]Question, 0)
+	err = json.Unmarshal([]byte(data), &questions)
+	return questions, err
+}
+
+//CurateDailyQuestions sets the question set of today or a later day. The
+//questions should be live, in the language of the challenge and distinct. A
+//day whose set is already stored, curated or selected when it was first
+//needed, is not changed so that every player gets the same questions.
+func CurateDailyQuestions(data CurateData) error {
+	if _, err := time.Parse("2006-01-02", data.Date); err != nil {
+		return errors.New("date should be yyyy-mm-dd")
+	}
+	if data.Date < today() {
+		return errors.New("the date is in the past")
+	}
+	if !LanguageEnabled(data.Language) {
+		return errors.New("unknown language")
+	}
+	if len(data.QuestionIDs) == 0 {
+		return errors.New("choose the questions")
+	}
+	questions := make([]Question, 1, len(data.QuestionIDs)+1)
+	questions[0] = Question{PlayerAnswers: make(map[string]string)}
+	chosen := make(map[string]bool)
+	for _, id := range data.QuestionIDs {
+		if chosen[id] {
+			return errors.New("question " + id + " is chosen twice")
+		}
+		chosen[id] = true
+		question, err := QuestionRepo.GetQuestion(id)
+		if err != nil {
+			return err
+		}
+		if question.State != Live {
+			return errors.New("question " + id + " is not live")
+		}
+		if question.Language != data.Language {
+			return errors.New("question " + id + " is not in " + data.Language.String())
+		}
+		question.PlayerAnswers = make(map[string]string)
+		questions = append(questions, question)
+	}
+	questionsJSON, err := json.Marshal(questions)
+	if err != nil {
+		return err
+	}
+	created, err := database.RedisClient.SetNX(dailyQuestionsKey(data.Date, data.Language), string(questionsJSON), DailyChallengeRetention).Result()
+	if err != nil {
+		return err
+	}
+	if !created {
+		return ErrDailyQuestionsSet
+	}
+	return nil
+}
+
+//today date of the current daily challenge
+func today() string {
+	return periodID(Daily, time.Now())
+}
+
+//StartDailyChallenge creates the daily challenge game of the player, played
+//with the async game endpoints until the end of the day. A player plays it
+//once, starting again returns the unfinished game. The slot of the player is
+//reserved with the game id before the game is stored.
+func StartDailyChallenge(userID string, language Language) (*Game, error) {
+	if !LanguageEnabled(language) {
+		return nil, errors.New("unknown language")
+	}
+	date := today()
+	gameID, err := database.RedisClient.HGet(dailyGamesKey(date, language), userID).Result()
+	if err == nil {
+		game, err := GetGame(gameID)
+		if err != nil {
+			return nil, err
+		}
+		if game.Status != Active {
+			return nil, ErrDailyChallengePlayed
+		}
+		return game, nil
+	} else if err != redis.Nil {
+		return nil, err
+	}
+	questions, err := GetDailyQuestions(date, language)
+	if err != nil {
+		return nil, err
+	}
+	profile, err := GetPublicProfile(userID)
+	if err != nil {
+		return nil, err
+	}
+	end, _ := time.Parse("2006-01-02", date)
+	game := &Game{
+		Language:        language,
+		MaxQuestions:    len(questions) - 1,
+		NumberOfPlayers: 1,
+		Players: map[string]Player{
+			userID: {ID: userID, Name: profile.DisplayName, Avatar: profile.Avatar},
+		},
+		Status:        Active,
+		Questions:     questions,
+		Scores:        map[string][]int{userID: make([]int, len(questions))},
+		Mode:          ModeDaily,
+		Deadline:      end.AddDate(0, 0, 1).Unix(),
+		Progress:      map[string]int{userID: 0},
+		PlayerShownAt: make(map[string]int64),
+	}
+	game.ID, err = newGameID()
+	if err != nil {
+		return nil, err
+	}
+	game.CreatedTimestamp = time.Now().Unix()
+	created, err := database.RedisClient.HSetNX(dailyGamesKey(date, language), userID, game.ID).Result()
+	if err != nil {
+		return nil, err
+	}
+	if !created {
+		return nil, ErrDailyChallengePlayed
+	}
+	database.RedisClient.Expire(dailyGamesKey(date, language), DailyChallengeRetention)
+	if _, err := SaveGame(game); err != nil {
+		database.RedisClient.HDel(dailyGamesKey(date, language), userID)
+		return nil, err
+	}
+	database.RedisClient.ZAdd(asyncDeadlinesKey, redis.Z{Score: float64(game.Deadline), Member: game.ID})
+	return game, nil
+}
+
+//RecordDailyChallengeScore adds the finished daily challenge to the board of its day
+func RecordDailyChallengeScore(game *Game) {
+	date := periodID(Daily, time.Unix(game.CreatedTimestamp, 0))
+	key := leaderboardKey(DailyChallenge, date, languageScope(game.Language))
+	for _, result := range game.Results {
+		database.RedisClient.ZAdd(key, redis.Z{Score: float64(result.Score), Member: result.PlayerID})
+	}
+	database.RedisClient.Expire(key, DailyChallengeRetention)
+}
+
+//GetDailyChallengeStatus returns the daily challenge of the language for the player
+func GetDailyChallengeStatus(userID string, language Language) (*DailyChallengeStatus, error) {
+	date := today()
+	status := &DailyChallengeStatus{Date: date, Language: language, Questions: NumOfQuestionsInGame}
+	gameID, err := database.RedisClient.HGet(dailyGamesKey(date, language), userID).Result()
+	if err == redis.Nil {
+		return status, nil
+	} else if err != nil {
+		return nil, err
+	}
+	game, err := GetGame(gameID)
+	if err != nil {
+		return nil, err
+	}
+	status.GameID = game.ID
+	status.Questions = game.MaxQuestions
+	status.Finished = game.Status != Active
+	for _, score := range game.Scores[userID] {
+		status.Score += score
+	}
+	return status, nil
+}
+
+func languageParam(c *gin.Context) (Language, bool) {
+	language, err := strconv.Atoi(c.Query("language"))
+	if err != nil || !LanguageEnabled(Language(language)) {
+		return 0, false
+	}
+	return Language(language), true
+}
+
+//GetDailyChallenge returns the daily challenge of the language for the logged in player
+func GetDailyChallenge(c *gin.Context) {
+	language, ok := languageParam(c)
+	if !ok {
+		sendError(c, "unknown language")
+		return
+	}
+	status, err := GetDailyChallengeStatus(CurrentUserID(c), language)
+	if err != nil {
+		sendError(c, "error while getting the daily challenge")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"dailyChallenge": status,
+	})
+}
+
+//PlayDailyChallenge starts the daily challenge of the language
+func PlayDailyChallenge(c *gin.Context) {
+	language, ok := languageParam(c)
+	if !ok {
+		sendError(c, "unknown language")
+		return
+	}
+	game, err := StartDailyChallenge(CurrentUserID(c), language)
+	if err == ErrDailyChallengePlayed {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	} else if err != nil {
+		sendError(c, "error while starting the daily challenge")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"gameID":   game.ID,
+		"deadline": game.Deadline,
+	})
+}
+
+//GetDailyChallengeLeaderboard returns the board of the daily challenge of
+//the language, today unless a date is given
+func GetDailyChallengeLeaderboard(c *gin.Context) {
+	language, ok := languageParam(c)
+	if !ok {
+		sendError(c, "unknown language")
+		return
+	}
+	date := c.DefaultQuery("date", today())
+	leaderboard, err := GetLeaderboard(DailyChallenge, date, languageScope(language), DefaultLeaderboardSize, CurrentUserID(c))
+	if err != nil {
+		sendError(c, "error while getting the leaderboard")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"leaderboard": leaderboard,
+	})
+}
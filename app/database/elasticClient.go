@@ -5,16 +5,37 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"log"
+	"net/http"
 	"os"
+	"time"
 
 	"github.com/elastic/go-elasticsearch/v8"
+	"github.com/elastic/go-elasticsearch/v8/esapi"
 )
 
 //elasticClient elastic client used for elastic search access
 var elasticClient *elasticsearch.Client
 var indexName = "questions"
 
+//ErrNotFound returned when the document does not exist in the index
+var ErrNotFound = errors.New("document not found")
+
+//SearchHit a single document returned by elastic search
+type SearchHit struct {
+	ID     string          `json:"_id"`
+	Source json.RawMessage `json:"_source"`
+}
+
+//SearchResult hits section of the elastic search response
+type SearchResult struct {
+	Total struct {
+		Value int `json:"value"`
+	} `json:"total"`
+	Hits []SearchHit `json:"hits"`
+}
+
 //InitElastic function initialises the
 func InitElastic() {
 	var err error
@@ -30,13 +51,15 @@ func InitElastic() {
 }
 
 //SearchQuestions is used to search for questions
-func SearchQuestions(searchQuery map[string]interface{}) (map[string]interface{}, error) {
+func SearchQuestions(searchQuery map[string]interface{}) (*SearchResult, error) {
 	if elasticClient == nil {
 		return nil, errors.New("empty elastic client")
 	}
 
 	var buf bytes.Buffer
-	var result map[string]interface{}
+	var result struct {
+		Hits SearchResult `json:"hits"`
+	}
 
 	if err := json.NewEncoder(&buf).Encode(searchQuery); err != nil {
 		return nil, err
@@ -47,18 +70,369 @@ func SearchQuestions(searchQuery map[string]interface{}) (map[string]interface{}
 		elasticClient.Search.WithIndex(indexName),
 		elasticClient.Search.WithBody(&buf),
 		elasticClient.Search.WithTrackTotalHits(true),
-		elasticClient.Search.WithPretty(),
 	)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		return nil, responseError(res)
+	}
 
-	if err != nil || res.IsError() {
+	if err := json.NewDecoder(res.Body).Decode(&result); err != nil {
 		return nil, err
 	}
 
+	return &result.Hits, nil
+}
+
+//GetQuestion returns the source of the question document with the id
+func GetQuestion(id string) (json.RawMessage, error) {
+	if elasticClient == nil {
+		return nil, errors.New("empty elastic client")
+	}
+
+	res, err := elasticClient.Get(indexName, id, elasticClient.Get.WithContext(context.Background()))
+	if err != nil {
+		return nil, err
+	}
 	defer res.Body.Close()
+	if res.StatusCode == http.StatusNotFound {
+		return nil, ErrNotFound
+	}
+	if res.IsError() {
+		return nil, responseError(res)
+	}
+
+	var hit SearchHit
+	if err := json.NewDecoder(res.Body).Decode(&hit); err != nil {
+		return nil, err
+	}
+	return hit.Source, nil
+}
+
+//UpdateQuestion updates the fields of the question document with the id
+func UpdateQuestion(id string, fields interface{}) error {
+	if elasticClient == nil {
+		return errors.New("empty elastic client")
+	}
+
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(map[string]interface{}{"doc": fields}); err != nil {
+		return err
+	}
 
+	res, err := elasticClient.Update(indexName, id, &buf,
+		elasticClient.Update.WithContext(context.Background()),
+		elasticClient.Update.WithRefresh("true"),
+	)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode == http.StatusNotFound {
+		return ErrNotFound
+	}
+	if res.IsError() {
+		return responseError(res)
+	}
+	return nil
+}
+
+//IndexQuestion stores the question document, an empty id generates a new id.
+//The id of the stored document is returned.
+func IndexQuestion(id string, document interface{}) (string, error) {
+	if elasticClient == nil {
+		return "", errors.New("empty elastic client")
+	}
+
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(document); err != nil {
+		return "", err
+	}
+
+	options := []func(*esapi.IndexRequest){
+		elasticClient.Index.WithContext(context.Background()),
+		elasticClient.Index.WithRefresh("true"),
+	}
+	if id != "" {
+		options = append(options, elasticClient.Index.WithDocumentID(id))
+	}
+	res, err := elasticClient.Index(indexName, &buf, options...)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		return "", responseError(res)
+	}
+
+	var hit SearchHit
+	if err := json.NewDecoder(res.Body).Decode(&hit); err != nil {
+		return "", err
+	}
+	return hit.ID, nil
+}
+
+//DeleteQuestion deletes the question document with the id
+func DeleteQuestion(id string) error {
+	if elasticClient == nil {
+		return errors.New("empty elastic client")
+	}
+
+	res, err := elasticClient.Delete(indexName, id,
+		elasticClient.Delete.WithContext(context.Background()),
+		elasticClient.Delete.WithRefresh("true"),
+	)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode == http.StatusNotFound {
+		return ErrNotFound
+	}
+	if res.IsError() {
+		return responseError(res)
+	}
+	return nil
+}
+
+//EnsureQuestionIndex creates the questions index with the mapping, or adds the
+//properties missing in the mapping of the existing index. Properties which are
+//already mapped cannot change their type and are left as they are. The types
+//of the properties of the index are returned.
+func EnsureQuestionIndex(mapping map[string]interface{}) (map[string]string, error) {
+	if elasticClient == nil {
+		return nil, errors.New("empty elastic client")
+	}
+
+	res, err := elasticClient.Indices.Exists([]string{indexName})
+	if err != nil {
+		return nil, err
+	}
+	res.Body.Close()
+
+	var buf bytes.Buffer
+	if res.StatusCode == http.StatusNotFound {
+		if err := json.NewEncoder(&buf).Encode(map[string]interface{}{"mappings": mapping}); err != nil {
+			return nil, err
+		}
+		res, err = elasticClient.Indices.Create(indexName, elasticClient.Indices.Create.WithBody(&buf))
+	} else {
+		var existing map[string]string
+		existing, err = questionFieldTypes()
+		if err != nil {
+			return nil, err
+		}
+		properties, _ := mapping["properties"].(map[string]interface{})
+		missing := make(map[string]interface{})
+		for field, property := range properties {
+			if _, ok := existing[field]; !ok {
+				missing[field] = property
+			}
+		}
+		if len(missing) == 0 {
+			return existing, nil
+		}
+		if err := json.NewEncoder(&buf).Encode(map[string]interface{}{"properties": missing}); err != nil {
+			return nil, err
+		}
+		res, err = elasticClient.Indices.PutMapping([]string{indexName}, &buf)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		return nil, responseError(res)
+	}
+	return questionFieldTypes()
+}
+
+//questionFieldTypes returns the type of each property of the questions index
+func questionFieldTypes() (map[string]string, error) {
+	res, err := elasticClient.Indices.GetMapping(elasticClient.Indices.GetMapping.WithIndex(indexName))
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		return nil, responseError(res)
+	}
+
+	var result map[string]struct {
+		Mappings struct {
+			Properties map[string]struct {
+				Type string `json:"type"`
+			} `json:"properties"`
+		} `json:"mappings"`
+	}
 	if err := json.NewDecoder(res.Body).Decode(&result); err != nil {
 		return nil, err
 	}
+	types := make(map[string]string)
+	for _, index := range result {
+		for field, property := range index.Mappings.Properties {
+			types[field] = property.Type
+			if property.Type == "" {
+				types[field] = "object"
+			}
+		}
+	}
+	return types, nil
+}
+
+//BulkIndexQuestions indexes the question documents in a single request. The
+//returned slices hold the id or the error of each document in order.
+func BulkIndexQuestions(documents []interface{}) ([]string, []error, error) {
+	if elasticClient == nil {
+		return nil, nil, errors.New("empty elastic client")
+	}
+
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	for _, document := range documents {
+		action := map[string]interface{}{"index": map[string]string{"_index": indexName}}
+		if err := encoder.Encode(action); err != nil {
+			return nil, nil, err
+		}
+		if err := encoder.Encode(document); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	res, err := elasticClient.Bulk(&buf,
+		elasticClient.Bulk.WithContext(context.Background()),
+		elasticClient.Bulk.WithRefresh("true"),
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		return nil, nil, responseError(res)
+	}
+
+	var result struct {
+		Items []map[string]struct {
+			ID     string `json:"_id"`
+			Status int    `json:"status"`
+			Error  struct {
+				Reason string `json:"reason"`
+			} `json:"error"`
+		} `json:"items"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&result); err != nil {
+		return nil, nil, err
+	}
+	ids := make([]string, len(documents))
+	itemErrors := make([]error, len(documents))
+	for i, item := range result.Items {
+		if i >= len(documents) {
+			break
+		}
+		indexed := item["index"]
+		if indexed.Status >= http.StatusMultipleChoices {
+			itemErrors[i] = errors.New(indexed.Error.Reason)
+		} else {
+			ids[i] = indexed.ID
+		}
+	}
+	return ids, itemErrors, nil
+}
+
+//BulkUpdateQuestions updates the fields of the question documents keyed by
+//their id in a single request
+func BulkUpdateQuestions(fields map[string]interface{}) error {
+	if elasticClient == nil {
+		return errors.New("empty elastic client")
+	}
+
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	for id, documentFields := range fields {
+		action := map[string]interface{}{"update": map[string]string{"_index": indexName, "_id": id}}
+		if err := encoder.Encode(action); err != nil {
+			return err
+		}
+		if err := encoder.Encode(map[string]interface{}{"doc": documentFields}); err != nil {
+			return err
+		}
+	}
+
+	res, err := elasticClient.Bulk(&buf,
+		elasticClient.Bulk.WithContext(context.Background()),
+		elasticClient.Bulk.WithRefresh("true"),
+	)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		return responseError(res)
+	}
+
+	var result struct {
+		Errors bool `json:"errors"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&result); err != nil {
+		return err
+	}
+	if result.Errors {
+		return errors.New("elastic search error: some documents were not updated")
+	}
+	return nil
+}
+
+//ScrollQuestions calls handle with every page of the questions matching the query
+func ScrollQuestions(searchQuery map[string]interface{}, handle func([]SearchHit) error) error {
+	if elasticClient == nil {
+		return errors.New("empty elastic client")
+	}
+
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(searchQuery); err != nil {
+		return err
+	}
+
+	res, err := elasticClient.Search(
+		elasticClient.Search.WithContext(context.Background()),
+		elasticClient.Search.WithIndex(indexName),
+		elasticClient.Search.WithBody(&buf),
+		elasticClient.Search.WithScroll(time.Minute),
+	)
+	for {
+		if err != nil {
+			return err
+		}
+		var result struct {
+			ScrollID string       `json:"_scroll_id"`
+			Hits     SearchResult `json:"hits"`
+		}
+		if res.IsError() {
+			err = responseError(res)
+		} else {
+			err = json.NewDecoder(res.Body).Decode(&result)
+		}
+		res.Body.Close()
+		if err != nil {
+			return err
+		}
+		if len(result.Hits.Hits) == 0 {
+			elasticClient.ClearScroll(elasticClient.ClearScroll.WithScrollID(result.ScrollID))
+			return nil
+		}
+		if err := handle(result.Hits.Hits); err != nil {
+			elasticClient.ClearScroll(elasticClient.ClearScroll.WithScrollID(result.ScrollID))
+			return err
+		}
+		res, err = elasticClient.Scroll(
+			elasticClient.Scroll.WithScrollID(result.ScrollID),
+			elasticClient.Scroll.WithScroll(time.Minute),
+		)
+	}
+}
 
-	return result["hits"].(map[string]interface{}), nil
+func responseError(res *esapi.Response) error {
+	return fmt.Errorf("elastic search error: %s", res.String())
 }
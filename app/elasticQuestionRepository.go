@@ -0,0 +1,325 @@
+package app
+
+import (
+	"encoding/json"
+	"log"
+	"sharequiz/app/database"
+)
+
+//maxTermsPerQuery terms sent in a single terms query, well below the
+//index.max_terms_count of elastic search
+const maxTermsPerQuery = 1000
+
+//ElasticQuestionRepository questions stored in the elastic search questions index
+type ElasticQuestionRepository struct{}
+
+//NewElasticQuestionRepository creates the elastic search question repository
+func NewElasticQuestionRepository() *ElasticQuestionRepository {
+	return &ElasticQuestionRepository{}
+}
+
+//RandomQuestions returns random questions using the random_score function
+func (r *ElasticQuestionRepository) RandomQuestions(filter QuestionFilter, count int) ([]Question, error) {
+	randomScore := map[string]interface{}{}
+	if filter.Seed != 0 {
+		randomScore["seed"] = filter.Seed
+		randomScore["field"] = "_seq_no"
+	}
+	randomScoreQuery := map[string]interface{}{
+		"random_score": randomScore,
+	}
+	functionsMap := []map[string]interface{}{randomScoreQuery}
+
+	boolQuery := map[string]interface{}{
+		"filter": filterQueries(filter),
+		"must": map[string]interface{}{
+			"function_score": map[string]interface{}{
+				"functions": functionsMap,
+			},
+		},
+	}
+	if len(filter.ExcludeIDs) > 0 {
+		boolQuery["must_not"] = map[string]interface{}{
+			"ids": map[string][]string{
+				"values": filter.ExcludeIDs,
+			},
+		}
+	}
+
+	query := map[string]interface{}{
+		"size": count,
+		"query": map[string]interface{}{
+			"bool": boolQuery,
+		},
+	}
+
+	result, err := database.SearchQuestions(query)
+	if err != nil {
+		return nil, err
+	}
+	return hitsToQuestions(result.Hits), nil
+}
+
+//GetQuestion returns the question document with the id
+func (r *ElasticQuestionRepository) GetQuestion(id string) (Question, error) {
+	source, err := database.GetQuestion(id)
+	if err == database.ErrNotFound {
+		return Question{}, ErrQuestionNotFound
+	} else if err != nil {
+		return Question{}, err
+	}
+	document := questionDocument{}
+	if err := json.Unmarshal(source, &document); err != nil {
+		return Question{}, err
+	}
+	return document.toQuestion(id)
+}
+
+//SetDifficulty updates the difficulty field of the question document
+func (r *ElasticQuestionRepository) SetDifficulty(id string, difficulty Difficulty) error {
+	err := database.UpdateQuestion(id, map[string]interface{}{"difficulty": difficulty})
+	if err == database.ErrNotFound {
+		return ErrQuestionNotFound
+	}
+	return err
+}
+
+//SetState updates the state field of the question document
+func (r *ElasticQuestionRepository) SetState(id string, state QuestionState) error {
+	err := database.UpdateQuestion(id, map[string]interface{}{"state": state})
+	if err == database.ErrNotFound {
+		return ErrQuestionNotFound
+	}
+	return err
+}
+
+//SearchQuestions searches the question text within the filter
+func (r *ElasticQuestionRepository) SearchQuestions(search QuestionSearch) ([]Question, int, error) {
+	textQuery := map[string]interface{}{
+		"match_all": map[string]interface{}{},
+	}
+	if search.Text != "" {
+		textQuery = map[string]interface{}{
+			"match": map[string]string{
+				"question_text": search.Text,
+			},
+		}
+	}
+	query := map[string]interface{}{
+		"from": search.From,
+		"size": search.Size,
+		"query": map[string]interface{}{
+			"bool": map[string]interface{}{
+				"filter": filterQueries(search.QuestionFilter),
+				"must":   textQuery,
+			},
+		},
+	}
+
+	result, err := database.SearchQuestions(query)
+	if err != nil {
+		return nil, 0, err
+	}
+	return hitsToQuestions(result.Hits), result.Total.Value, nil
+}
+
+//CreateQuestion indexes a new question document
+func (r *ElasticQuestionRepository) CreateQuestion(question Question) (Question, error) {
+	id, err := database.IndexQuestion("", newQuestionDocument(question))
+	if err != nil {
+		return Question{}, err
+	}
+	return r.GetQuestion(id)
+}
+
+//UpdateQuestion indexes the whole question document again, a partial update
+//would keep the fields missing in the question
+func (r *ElasticQuestionRepository) UpdateQuestion(question Question) (Question, error) {
+	if _, err := database.GetQuestion(question.ID); err == database.ErrNotFound {
+		return Question{}, ErrQuestionNotFound
+	} else if err != nil {
+		return Question{}, err
+	}
+	if _, err := database.IndexQuestion(question.ID, newQuestionDocument(question)); err != nil {
+		return Question{}, err
+	}
+	return r.GetQuestion(question.ID)
+}
+
+//DeleteQuestion deletes the question document
+func (r *ElasticQuestionRepository) DeleteQuestion(id string) error {
+	err := database.DeleteQuestion(id)
+	if err == database.ErrNotFound {
+		return ErrQuestionNotFound
+	}
+	return err
+}
+
+//BulkCreateQuestions indexes the questions with a bulk request
+func (r *ElasticQuestionRepository) BulkCreateQuestions(questions []Question) ([]error, error) {
+	documents := make([]interface{}, len(questions))
+	for i, question := range questions {
+		documents[i] = newQuestionDocument(question)
+	}
+	_, itemErrors, err := database.BulkIndexQuestions(documents)
+	return itemErrors, err
+}
+
+//ExistingQuestionTexts looks up the normalized texts of the question documents
+func (r *ElasticQuestionRepository) ExistingQuestionTexts(normalizedTexts []string) (map[string]bool, error) {
+	existing := make(map[string]bool)
+	for start := 0; start < len(normalizedTexts); start += maxTermsPerQuery {
+		end := start + maxTermsPerQuery
+		if end > len(normalizedTexts) {
+			end = len(normalizedTexts)
+		}
+		query := map[string]interface{}{
+			"size":    1000,
+			"_source": []string{"normalized_text"},
+			"query": map[string]interface{}{
+				"terms": map[string][]string{
+					"normalized_text": normalizedTexts[start:end],
+				},
+			},
+		}
+		err := database.ScrollQuestions(query, func(hits []database.SearchHit) error {
+			for _, hit := range hits {
+				document := questionDocument{}
+				if err := json.Unmarshal(hit.Source, &document); err != nil {
+					return err
+				}
+				existing[document.NormalizedText] = true
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return existing, nil
+}
+
+//BackfillNormalizedTexts adds the normalized text to the questions stored
+//before the duplicate check, so that imports find them
+func (r *ElasticQuestionRepository) BackfillNormalizedTexts() error {
+	query := map[string]interface{}{
+		"size":    500,
+		"sort":    []string{"_doc"},
+		"_source": []string{"question_text"},
+		"query": map[string]interface{}{
+			"bool": map[string]interface{}{
+				"must_not": map[string]interface{}{
+					"exists": map[string]string{"field": "normalized_text"},
+				},
+			},
+		},
+	}
+	updated := 0
+	err := database.ScrollQuestions(query, func(hits []database.SearchHit) error {
+		fields := make(map[string]interface{}, len(hits))
+		for _, hit := range hits {
+			document := questionDocument{}
+			if err := json.Unmarshal(hit.Source, &document); err != nil {
+				log.Println("skipping question "+hit.ID, err)
+				continue
+			}
+			fields[hit.ID] = map[string]string{"normalized_text": NormalizeQuestionText(document.QuestionText)}
+		}
+		if len(fields) == 0 {
+			return nil
+		}
+		updated += len(fields)
+		return database.BulkUpdateQuestions(fields)
+	})
+	if updated > 0 {
+		log.Println("added the normalized text to", updated, "questions")
+	}
+	return err
+}
+
+//ExportQuestions scrolls through the questions matching the filter
+func (r *ElasticQuestionRepository) ExportQuestions(filter QuestionFilter, handle func(Question) error) error {
+	query := map[string]interface{}{
+		"size": 500,
+		"sort": []string{"_doc"},
+		"query": map[string]interface{}{
+			"bool": map[string]interface{}{
+				"filter": filterQueries(filter),
+			},
+		},
+	}
+	return database.ScrollQuestions(query, func(hits []database.SearchHit) error {
+		for _, question := range hitsToQuestions(hits) {
+			if err := handle(question); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func filterQueries(filter QuestionFilter) []map[string]interface{} {
+	filterQuery := make([]map[string]interface{}, 0)
+	if filter.Topic != 0 {
+		filterQuery = append(filterQuery, map[string]interface{}{
+			"terms": map[string][]string{
+				"topics": {filter.Topic.Key()},
+			},
+		})
+	}
+	if filter.Language != 0 {
+		filterQuery = append(filterQuery, map[string]interface{}{
+			"term": map[string]string{
+				"language": filter.Language.Key(),
+			},
+		})
+	}
+	if filter.Difficulty != 0 {
+		filterQuery = append(filterQuery, map[string]interface{}{
+			"term": map[string]int{
+				"difficulty": int(filter.Difficulty),
+			},
+		})
+	}
+	if filter.State == Live {
+		filterQuery = append(filterQuery, map[string]interface{}{
+			"bool": map[string]interface{}{
+				"should": []map[string]interface{}{
+					{"term": map[string]int{"state": int(Live)}},
+					{"bool": map[string]interface{}{
+						"must_not": map[string]interface{}{
+							"exists": map[string]string{"field": "state"},
+						},
+					}},
+				},
+				"minimum_should_match": 1,
+			},
+		})
+	} else if filter.State != 0 {
+		filterQuery = append(filterQuery, map[string]interface{}{
+			"term": map[string]int{
+				"state": int(filter.State),
+			},
+		})
+	}
+	return filterQuery
+}
+
+//hitsToQuestions converts the hits skipping the malformed documents
+func hitsToQuestions(hits []database.SearchHit) []Question {
+	questions := make([]Question, 0, len(hits))
+	for _, hit := range hits {
+		document := questionDocument{}
+		if err := json.Unmarshal(hit.Source, &document); err != nil {
+			log.Println("skipping question "+hit.ID, err)
+			continue
+		}
+		question, err := document.toQuestion(hit.ID)
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+		questions = append(questions, question)
+	}
+	return questions
+}
@@ -0,0 +1,255 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"math/rand"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+//FileQuestionRepository questions loaded from a json or yaml file, used for
+//developing and testing without elastic search
+type FileQuestionRepository struct {
+	path      string
+	mutex     sync.RWMutex
+	questions []fileQuestion
+}
+
+type fileQuestion struct {
+	ID               string `json:"id" yaml:"id"`
+	questionDocument `yaml:",inline"`
+}
+
+//NewFileQuestionRepository loads the questions from the json or yaml file at path
+func NewFileQuestionRepository(path string) (*FileQuestionRepository, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	questions := make([]fileQuestion, 0)
+	if isYAMLFile(path) {
+		err = yaml.Unmarshal(data, &questions)
+	} else {
+		err = json.Unmarshal(data, &questions)
+	}
+	if err != nil {
+		return nil, err
+	}
+	ids := make(map[string]bool)
+	for _, question := range questions {
+		if question.ID == "" || ids[question.ID] {
+			return nil, errors.New("missing or duplicate question id in " + path)
+		}
+		ids[question.ID] = true
+	}
+	return &FileQuestionRepository{path: path, questions: questions}, nil
+}
+
+//RandomQuestions returns random questions matching the filter
+func (r *FileQuestionRepository) RandomQuestions(filter QuestionFilter, count int) ([]Question, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	questions := make([]Question, 0, count)
+	order := rand.Perm(len(r.questions))
+	if filter.Seed != 0 {
+		order = rand.New(rand.NewSource(filter.Seed)).Perm(len(r.questions))
+	}
+	for _, i := range order {
+		if len(questions) == count {
+			break
+		}
+		stored := r.questions[i]
+		if !stored.matches(stored.ID, filter) {
+			continue
+		}
+		question, err := stored.toQuestion(stored.ID)
+		if err != nil {
+			continue
+		}
+		questions = append(questions, question)
+	}
+	return questions, nil
+}
+
+//GetQuestion returns the question with the id
+func (r *FileQuestionRepository) GetQuestion(id string) (Question, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	i := r.indexOf(id)
+	if i < 0 {
+		return Question{}, ErrQuestionNotFound
+	}
+	return r.questions[i].toQuestion(id)
+}
+
+//SetDifficulty updates the difficulty of the question
+func (r *FileQuestionRepository) SetDifficulty(id string, difficulty Difficulty) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	i := r.indexOf(id)
+	if i < 0 {
+		return ErrQuestionNotFound
+	}
+	r.questions[i].Difficulty = difficulty
+	return r.save()
+}
+
+//SetState updates the state of the question
+func (r *FileQuestionRepository) SetState(id string, state QuestionState) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	i := r.indexOf(id)
+	if i < 0 {
+		return ErrQuestionNotFound
+	}
+	r.questions[i].State = state
+	return r.save()
+}
+
+//SearchQuestions returns a page of the questions containing the text
+func (r *FileQuestionRepository) SearchQuestions(search QuestionSearch) ([]Question, int, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	questions := make([]Question, 0)
+	total := 0
+	for _, stored := range r.questions {
+		if !stored.matches(stored.ID, search.QuestionFilter) || !stored.containsText(search.Text) {
+			continue
+		}
+		question, err := stored.toQuestion(stored.ID)
+		if err != nil {
+			continue
+		}
+		if total >= search.From && len(questions) < search.Size {
+			questions = append(questions, question)
+		}
+		total++
+	}
+	return questions, total, nil
+}
+
+//CreateQuestion adds the question to the file
+func (r *FileQuestionRepository) CreateQuestion(question Question) (Question, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	id := strconv.FormatInt(time.Now().UnixNano(), 36)
+	r.questions = append(r.questions, fileQuestion{ID: id, questionDocument: newQuestionDocument(question)})
+	if err := r.save(); err != nil {
+		return Question{}, err
+	}
+	return r.questions[len(r.questions)-1].toQuestion(id)
+}
+
+//UpdateQuestion replaces the question in the file
+func (r *FileQuestionRepository) UpdateQuestion(question Question) (Question, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	i := r.indexOf(question.ID)
+	if i < 0 {
+		return Question{}, ErrQuestionNotFound
+	}
+	r.questions[i].questionDocument = newQuestionDocument(question)
+	if err := r.save(); err != nil {
+		return Question{}, err
+	}
+	return r.questions[i].toQuestion(question.ID)
+}
+
+//DeleteQuestion removes the question from the file
+func (r *FileQuestionRepository) DeleteQuestion(id string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	i := r.indexOf(id)
+	if i < 0 {
+		return ErrQuestionNotFound
+	}
+	r.questions = append(r.questions[:i], r.questions[i+1:]...)
+	return r.save()
+}
+
+//BulkCreateQuestions adds all the questions to the file with a single write
+func (r *FileQuestionRepository) BulkCreateQuestions(questions []Question) ([]error, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	prefix := strconv.FormatInt(time.Now().UnixNano(), 36)
+	for i, question := range questions {
+		id := prefix + "-" + strconv.Itoa(i)
+		r.questions = append(r.questions, fileQuestion{ID: id, questionDocument: newQuestionDocument(question)})
+	}
+	if err := r.save(); err != nil {
+		return nil, err
+	}
+	return make([]error, len(questions)), nil
+}
+
+//ExistingQuestionTexts compares the texts with the normalized text of the questions in the file
+func (r *FileQuestionRepository) ExistingQuestionTexts(normalizedTexts []string) (map[string]bool, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	stored := make(map[string]bool)
+	for _, question := range r.questions {
+		stored[NormalizeQuestionText(question.QuestionText)] = true
+	}
+	existing := make(map[string]bool)
+	for _, text := range normalizedTexts {
+		if stored[text] {
+			existing[text] = true
+		}
+	}
+	return existing, nil
+}
+
+//ExportQuestions calls handle with the questions of the file matching the filter
+func (r *FileQuestionRepository) ExportQuestions(filter QuestionFilter, handle func(Question) error) error {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	for _, stored := range r.questions {
+		if !stored.matches(stored.ID, filter) {
+			continue
+		}
+		question, err := stored.toQuestion(stored.ID)
+		if err != nil {
+			continue
+		}
+		if err := handle(question); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *FileQuestionRepository) indexOf(id string) int {
+	for i, stored := range r.questions {
+		if stored.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+//save writes the questions back to the file, the caller holds the lock
+func (r *FileQuestionRepository) save() error {
+	var data []byte
+	var err error
+	if isYAMLFile(r.path) {
+		data, err = yaml.Marshal(r.questions)
+	} else {
+		data, err = json.MarshalIndent(r.questions, "", "  ")
+	}
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(r.path, data, 0644)
+}
+
+func isYAMLFile(path string) bool {
+	extension := strings.ToLower(filepath.Ext(path))
+	return extension == ".yaml" || extension == ".yml"
+}
@@ -0,0 +1,497 @@
+package app
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"regexp"
+	"sharequiz/app/database"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis"
+)
+
+const (
+	//MaxDiscoverNumbers contact phone numbers accepted in one discover request
+	MaxDiscoverNumbers = 500
+	//MaxDiscoverNumbersPerDay contact phone numbers a player can look up in a
+	//day, limits guessing the players behind phone numbers
+	MaxDiscoverNumbersPerDay = 2000
+	discoverWindow           = 24 * time.Hour
+	//DefaultCountryCode country code of the phone numbers verified without one
+	DefaultCountryCode = "91"
+)
+
+var e164Pattern = regexp.MustCompile(`^\+[1-9][0-9]{7,14}$`)
+
+//phoneHashSecret key of the phone number HMAC, set by InitPhoneHashes
+var phoneHashSecret []byte
+
+//Errors of the friend requests
+var (
+	ErrUnknownPlayer     = errors.New("player not found")
+	ErrNotFriends        = errors.New("player is not a friend")
+	ErrNoFriendRequest   = errors.New("friend request not found")
+	ErrFriendRequestSelf = errors.New("cannot add yourself")
+	ErrInvalidPhone      = errors.New("check the phone number")
+	ErrTooManyDiscovers  = errors.New("too many contacts looked up, try again tomorrow")
+	ErrDiscoveryDisabled = errors.New("friend discovery is not available")
+)
+
+//Friend friend of a player with the presence
+type Friend struct {
+	PublicProfile
+	Presence string `json:"presence"`
+}
+
+//FriendRequestData sent to add a friend
+type FriendRequestData struct {
+	UserID string `json:"userID"`
+}
+
+//FriendRequestAnswer sent to accept or decline a friend request
+type FriendRequestAnswer struct {
+	Accept bool `json:"accept"`
+}
+
+//DiscoverData phone numbers of the contacts in the E.164 format
+type DiscoverData struct {
+	PhoneNumbers []string `json:"phoneNumbers"`
+}
+
+//ChallengeData topic and language of a challenge to a friend
+type ChallengeData struct {
+	Topic    Topic    `json:"topic,string"`
+	Language Language `json:"language,string"`
+}
+
+func friendsKey(userID string) string {
+	return "friends-" + userID
+}
+
+func incomingRequestsKey(userID string) string {
+	return "friend-requests-in-" + userID
+}
+
+func outgoingRequestsKey(userID string) string {
+	return "friend-requests-out-" + userID
+}
+
+func phoneHashKey(hash string) string {
+	return "phone-hmac-" + hash
+}
+
+func discoverCountKey(userID string) string {
+	return "discover-count-" + userID
+}
+
+//NormalizePhoneNumber returns the phone number in the E.164 format, spaces,
+//dashes, dots and brackets are removed and a 00 prefix becomes +. Numbers
+//without a country code, like the 10 digit numbers verified by the app, get
+//the DefaultCountryCode.
+func NormalizePhoneNumber(phoneNumber string) (string, error) {
+	normalized := strings.Map(func(r rune) rune {
+		if strings.ContainsRune(" -.()", r) {
+			return -1
+		}
+		return r
+	}, phoneNumber)
+	switch {
+	case strings.HasPrefix(normalized, "00"):
+		normalized = "+" + normalized[2:]
+	case strings.HasPrefix(normalized, "+"):
+	case len(normalized) == 11 && strings.HasPrefix(normalized, "0"):
+		normalized = "+" + DefaultCountryCode + normalized[1:]
+	case len(normalized) == 10:
+		normalized = "+" + DefaultCountryCode + normalized
+	case len(normalized) == 10+len(DefaultCountryCode) && strings.HasPrefix(normalized, DefaultCountryCode):
+		normalized = "+" + normalized
+	}
+	if !e164Pattern.MatchString(normalized) {
+		return "", ErrInvalidPhone
+	}
+	return normalized, nil
+}
+
+//HashPhoneNumber HMAC of the normalized phone number with the server secret,
+//the phone numbers can not be recovered from the stored hashes
+func HashPhoneNumber(phoneNumber string) string {
+	mac := hmac.New(sha256.New, phoneHashSecret)
+	mac.Write([]byte(phoneNumber))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+//RegisterPhoneHash makes the player discoverable by the hash of the phone number
+func RegisterPhoneHash(phoneNumber string, userID string) error {
+	if len(phoneHashSecret) == 0 {
+		return ErrDiscoveryDisabled
+	}
+	normalized, err := NormalizePhoneNumber(phoneNumber)
+	if err != nil {
+		return err
+	}
+	return database.RedisClient.Set(phoneHashKey(HashPhoneNumber(normalized)), userID, 0).Err()
+}
+
+//InitPhoneHashes reads PHONE_HASH_SECRET and hashes the phone numbers of the
+//players again when the secret or the normalization changed, the hashes of the old secret and the
+//unsalted hashes are removed. Friend discovery is off without a secret.
+func InitPhoneHashes() {
+	secret := os.Getenv("PHONE_HASH_SECRET")
+	if secret == "" {
+		log.Println("PHONE_HASH_SECRET is not set, friend discovery is off")
+		return
+	}
+	phoneHashSecret = []byte(secret)
+	//the check changes with the secret and with the normalization version
+	check := HashPhoneNumber("phone-hash-check-2")
+	saved, err := database.RedisClient.Get("phone-hash-check").Result()
+	if err != nil && err != redis.Nil {
+		panic(err)
+	}
+	if saved == check {
+		return
+	}
+	for _, pattern := range []string{"phone-hash-*", phoneHashKey("*")} {
+		if err := deleteKeys(pattern); err != nil {
+			panic(err)
+		}
+	}
+	iter := database.RedisClient.Scan(0, userPhoneKey("*"), 1000).Iterator()
+	for iter.Next() {
+		userID := strings.TrimPrefix(iter.Val(), userPhoneKey(""))
+		phoneNumber, err := database.RedisClient.Get(iter.Val()).Result()
+		if err != nil {
+			continue
+		}
+		if err := RegisterPhoneHash(phoneNumber, userID); err != nil {
+			log.Println("phone number of player " + userID + " is not discoverable: " + err.Error())
+		}
+	}
+	if err := iter.Err(); err != nil {
+		panic(err)
+	}
+	database.RedisClient.Set("phone-hash-check", check, 0)
+}
+
+func deleteKeys(pattern string) error {
+	iter := database.RedisClient.Scan(0, pattern, 1000).Iterator()
+	for iter.Next() {
+		if err := database.RedisClient.Del(iter.Val()).Err(); err != nil {
+			return err
+		}
+	}
+	return iter.Err()
+}
+
+//UserExists tells if the user id belongs to a verified player
+func UserExists(userID string) bool {
+	exists, err := database.RedisClient.Exists(userPhoneKey(userID)).Result()
+	return err == nil && exists == 1
+}
+
+//AreFriends tells if the players are friends
+func AreFriends(userID string, friendID string) bool {
+	return database.RedisClient.SIsMember(friendsKey(userID), friendID).Val()
+}
+
+//SendFriendRequest sends a friend request, a pending request the other way
+//is accepted instead
+func SendFriendRequest(userID string, friendID string) error {
+	if userID == friendID {
+		return ErrFriendRequestSelf
+	}
+	if !UserExists(friendID) {
+		return ErrUnknownPlayer
+	}
+	if AreFriends(userID, friendID) {
+		return nil
+	}
+	if database.RedisClient.SIsMember(incomingRequestsKey(userID), friendID).Val() {
+		return AnswerFriendRequest(userID, friendID, true)
+	}
+	pipe := database.RedisClient.Pipeline()
+	pipe.SAdd(outgoingRequestsKey(userID), friendID)
+	pipe.SAdd(incomingRequestsKey(friendID), userID)
+	if _, err := pipe.Exec(); err != nil {
+		return err
+	}
+	return Notify(friendID, NotificationFriendRequest, userID, nil)
+}
+
+//AnswerFriendRequest accepts or declines the friend request of the requester
+func AnswerFriendRequest(userID string, requesterID string, accept bool) error {
+	removed, err := database.RedisClient.SRem(incomingRequestsKey(userID), requesterID).Result()
+	if err != nil {
+		return err
+	}
+	if removed == 0 {
+		return ErrNoFriendRequest
+	}
+	pipe := database.RedisClient.Pipeline()
+	pipe.SRem(outgoingRequestsKey(requesterID), userID)
+	if accept {
+		pipe.SAdd(friendsKey(userID), requesterID)
+		pipe.SAdd(friendsKey(requesterID), userID)
+	}
+	if _, err := pipe.Exec(); err != nil {
+		return err
+	}
+	if accept {
+		return Notify(requesterID, NotificationFriendAccepted, userID, nil)
+	}
+	return nil
+}
+
+//RemoveFriend removes the friendship of the players
+func RemoveFriend(userID string, friendID string) error {
+	pipe := database.RedisClient.Pipeline()
+	pipe.SRem(friendsKey(userID), friendID)
+	pipe.SRem(friendsKey(friendID), userID)
+	_, err := pipe.Exec()
+	return err
+}
+
+//GetFriendIDs returns the user ids of the friends of the player
+func GetFriendIDs(userID string) ([]string, error) {
+	return database.RedisClient.SMembers(friendsKey(userID)).Result()
+}
+
+//DiscoverFriends returns the players with the given phone numbers, invalid
+//numbers are skipped and every player can look up MaxDiscoverNumbersPerDay
+//numbers a day
+func DiscoverFriends(userID string, phoneNumbers []string) ([]PublicProfile, error) {
+	if len(phoneHashSecret) == 0 {
+		return nil, ErrDiscoveryDisabled
+	}
+	profiles := make([]PublicProfile, 0)
+	keys := make([]string, 0, len(phoneNumbers))
+	for _, phoneNumber := range phoneNumbers {
+		if normalized, err := NormalizePhoneNumber(phoneNumber); err == nil {
+			keys = append(keys, phoneHashKey(HashPhoneNumber(normalized)))
+		}
+	}
+	if len(keys) == 0 {
+		return profiles, nil
+	}
+	countKey := discoverCountKey(userID)
+	count, err := database.RedisClient.IncrBy(countKey, int64(len(keys))).Result()
+	if err != nil {
+		return nil, err
+	}
+	if count == int64(len(keys)) {
+		database.RedisClient.Expire(countKey, discoverWindow)
+	}
+	if count > MaxDiscoverNumbersPerDay {
+		return nil, ErrTooManyDiscovers
+	}
+	values, err := database.RedisClient.MGet(keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+	found := make(map[string]bool)
+	playerIDs := make([]string, 0)
+	for _, value := range values {
+		playerID, ok := value.(string)
+		if !ok || playerID == userID || found[playerID] {
+			continue
+		}
+		found[playerID] = true
+		playerIDs = append(playerIDs, playerID)
+	}
+	public, err := GetPublicProfiles(playerIDs)
+	if err != nil {
+		return nil, err
+	}
+	for _, playerID := range playerIDs {
+		profiles = append(profiles, public[playerID])
+	}
+	return profiles, nil
+}
+
+//ChallengeFriend creates a private room for the players and notifies the friend
+func ChallengeFriend(userID string, friendID string, data ChallengeData) (string, error) {
+	if !AreFriends(userID, friendID) {
+		return "", ErrNotFriends
+	}
+	if !TopicEnabled(data.Topic) || !LanguageEnabled(data.Language) {
+		return "", errors.New("check the topic and language")
+	}
+	roomID, err := NewRoom(GameRoom{
+		Language: data.Language,
+		Topic:    data.Topic,
+		Players:  []string{userID, friendID},
+	})
+	if err != nil {
+		return "", err
+	}
+	err = Notify(friendID, NotificationChallenge, userID, map[string]string{
+		"roomID":   roomID,
+		"topic":    data.Topic.String(),
+		"language": data.Language.String(),
+	})
+	return roomID, err
+}
+
+func profilesWithPresence(userIDs []string) []Friend {
+	presences := GetPresences(userIDs)
+	friends := make([]Friend, 0, len(userIDs))
+	for _, userID := range userIDs {
+		profile, err := GetPublicProfile(userID)
+		if err != nil {
+			continue
+		}
+		friends = append(friends, Friend{PublicProfile: profile, Presence: presences[userID]})
+	}
+	return friends
+}
+
+func publicProfiles(userIDs []string) []PublicProfile {
+	profiles := make([]PublicProfile, 0, len(userIDs))
+	for _, userID := range userIDs {
+		if profile, err := GetPublicProfile(userID); err == nil {
+			profiles = append(profiles, profile)
+		}
+	}
+	return profiles
+}
+
+//GetFriends returns the friends of the logged in player with their presence
+//and the pending friend requests
+func GetFriends(c *gin.Context) {
+	userID := CurrentUserID(c)
+	friendIDs, err := GetFriendIDs(userID)
+	if err != nil {
+		sendError(c, "error while getting the friends")
+		return
+	}
+	incoming, err := database.RedisClient.SMembers(incomingRequestsKey(userID)).Result()
+	if err != nil {
+		sendError(c, "error while getting the friends")
+		return
+	}
+	outgoing, err := database.RedisClient.SMembers(outgoingRequestsKey(userID)).Result()
+	if err != nil {
+		sendError(c, "error while getting the friends")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"friends":  profilesWithPresence(friendIDs),
+		"incoming": publicProfiles(incoming),
+		"outgoing": publicProfiles(outgoing),
+	})
+}
+
+//AddFriend sends a friend request
+func AddFriend(c *gin.Context) {
+	data := FriendRequestData{}
+	if err := c.ShouldBindJSON(&data); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "check the player",
+		})
+		return
+	}
+	if err := SendFriendRequest(CurrentUserID(c), data.UserID); err != nil {
+		sendFriendError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"message": "friend request sent",
+	})
+}
+
+//AnswerFriendRequestHandler accepts or declines the friend request of the player
+func AnswerFriendRequestHandler(c *gin.Context) {
+	data := FriendRequestAnswer{}
+	if err := c.ShouldBindJSON(&data); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "check the answer",
+		})
+		return
+	}
+	if err := AnswerFriendRequest(CurrentUserID(c), c.Param("id"), data.Accept); err != nil {
+		sendFriendError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"message": "friend request answered",
+	})
+}
+
+//DeleteFriend removes a friend
+func DeleteFriend(c *gin.Context) {
+	if err := RemoveFriend(CurrentUserID(c), c.Param("id")); err != nil {
+		sendError(c, "error while removing the friend")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"message": "friend removed",
+	})
+}
+
+//DiscoverFriendsHandler finds players from the phone numbers of the contacts
+func DiscoverFriendsHandler(c *gin.Context) {
+	data := DiscoverData{}
+	if err := c.ShouldBindJSON(&data); err != nil || len(data.PhoneNumbers) > MaxDiscoverNumbers {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "check the contacts",
+		})
+		return
+	}
+	profiles, err := DiscoverFriends(CurrentUserID(c), data.PhoneNumbers)
+	if err == ErrTooManyDiscovers {
+		c.JSON(http.StatusTooManyRequests, gin.H{
+			"message": err.Error(),
+		})
+		return
+	} else if err == ErrDiscoveryDisabled {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"message": err.Error(),
+		})
+		return
+	} else if err != nil {
+		sendError(c, "error while discovering friends")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"players": profiles,
+	})
+}
+
+//ChallengeFriendHandler creates a private room with a friend and notifies them
+func ChallengeFriendHandler(c *gin.Context) {
+	data := ChallengeData{}
+	if err := c.ShouldBindJSON(&data); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "check the topic and language",
+		})
+		return
+	}
+	roomID, err := ChallengeFriend(CurrentUserID(c), c.Param("id"), data)
+	if err != nil {
+		sendFriendError(c, err)
+		return
+	}
+	sendSuccess(c, roomID)
+}
+
+func sendFriendError(c *gin.Context, err error) {
+	switch err {
+	case ErrUnknownPlayer, ErrNoFriendRequest:
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": err.Error(),
+		})
+	case ErrNotFriends, ErrFriendRequestSelf:
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+	default:
+		sendError(c, err.Error())
+	}
+}
@@ -6,7 +6,6 @@ import (
 	"log"
 	"sharequiz/app/database"
 	"strconv"
-	"strings"
 	"time"
 
 	"github.com/go-redis/redis"
@@ -14,35 +13,76 @@ import (
 
 //Question object
 type Question struct {
-	QuestionText  string            `json:"questionText"`
-	Options       []string          `json:"options"`
-	Answer        string            `json:"answer"`
-	PlayerAnswers map[string]string `json:"playerAnswers"`
+	ID           string       `json:"id"`
+	Type         QuestionType `json:"type,omitempty"`
+	QuestionText string       `json:"questionText"`
+	ImageURL     string       `json:"imageURL,omitempty"`
+	Options      []string     `json:"options"`
+	Answer       string       `json:"answer"`
+	Tolerance    float64      `json:"tolerance,omitempty"`
+	//Explanations keyed by the language key
+	Explanations  map[string]Explanation `json:"explanations,omitempty"`
+	Topics        []Topic                `json:"topics,omitempty"`
+	Language      Language               `json:"language,string,omitempty"`
+	Difficulty    Difficulty             `json:"difficulty"`
+	State         QuestionState          `json:"state,omitempty"`
+	PlayerAnswers map[string]string      `json:"playerAnswers"`
+	AnswerTimes   map[string]int64       `json:"answerTimes,omitempty"`
 }
 
 // Game object status 1 is active, 2 is Disconnected and 3 is Finished
 type Game struct {
-	ID               string            `json:"id"`
-	Language         Language          `json:"language,string"`
-	MaxQuestions     int               `json:"maxQuestions"`
-	NumberOfPlayers  int               `json:"numberOfPlayers"`
-	QuestionNumber   int               `json:"questionNumber"`
-	Players          map[string]Player `json:"players"`
-	Status           Status            `json:"status,string"`
-	CreatedTimestamp int64             `json:"createdTimestamp"`
-	Questions        []Question        `json:"questions"`
-	Scores           map[string][]int  `json:"scores"`
+	ID               string                   `json:"id"`
+	Topic            Topic                    `json:"topic,string"`
+	Language         Language                 `json:"language,string"`
+	MaxQuestions     int                      `json:"maxQuestions"`
+	NumberOfPlayers  int                      `json:"numberOfPlayers"`
+	QuestionNumber   int                      `json:"questionNumber"`
+	QuestionShownAt  int64                    `json:"questionShownAt,omitempty"`
+	Players          map[string]Player        `json:"players"`
+	Status           Status                   `json:"status,string"`
+	CreatedTimestamp int64                    `json:"createdTimestamp"`
+	Questions        []Question               `json:"questions"`
+	Scores           map[string][]int         `json:"scores"`
+	Results          []PlayerResult           `json:"results,omitempty"`
+	Winner           string                   `json:"winner,omitempty"`
+	Voided           bool                     `json:"voided,omitempty"`
+	AdminActions     []GameAdminAction        `json:"adminActions,omitempty"`
+	Mode             string                   `json:"mode,omitempty"`
+	Deadline         int64                    `json:"deadline,omitempty"`
+	Progress         map[string]int           `json:"progress,omitempty"`
+	PlayerShownAt    map[string]int64         `json:"playerShownAt,omitempty"`
+	Lifelines        map[string][]LifelineUse `json:"lifelines,omitempty"`
+	//Participants players the game was created for, only they can join it
+	Participants []string `json:"participants,omitempty"`
 }
 
 // Player object
 type Player struct {
 	ID       string `json:"id"`
+	Name     string `json:"name"`
+	Avatar   string `json:"avatar"`
 	Score    int    `json:"score"`
 	Selected int    `json:"selected"`
 }
 
-// CreateGame function
-func CreateGame(maxQuestions int, language Language, numberOfPlayers int, topic Topic) (string, error) {
+//CanJoin tells if the player can join the game, games created without
+//players like the admin test games are open
+func (g *Game) CanJoin(playerID string) bool {
+	if _, ok := g.Players[playerID]; ok || len(g.Participants) == 0 {
+		return true
+	}
+	for _, participant := range g.Participants {
+		if participant == playerID {
+			return true
+		}
+	}
+	return false
+}
+
+// CreateGame function, playerIDs are the players known at creation whose seen
+// questions are avoided
+func CreateGame(maxQuestions int, language Language, numberOfPlayers int, topic Topic, playerIDs []string) (string, error) {
 	// 3 tries to create game
 	for i := 1; i <= 3; i++ {
 		gameID := 0
@@ -57,14 +97,18 @@ func CreateGame(maxQuestions int, language Language, numberOfPlayers int, topic
 			gameID++
 		}
 
-		questions, err := GetGameQuestions(topic, language, maxQuestions)
-		if err != nil {
+		questions, err := GetGameQuestions(topic, language, maxQuestions, playerIDs)
+		if err == ErrNotEnoughQuestions {
+			log.Println("not enough questions for " + topic.String() + " in " + language.String())
+			return "error", err
+		} else if err != nil {
 			log.Println("error while creating game ")
 			continue
 		}
 
 		data := Game{
 			ID:               strconv.Itoa(gameID),
+			Topic:            topic,
 			Language:         language,
 			MaxQuestions:     maxQuestions,
 			NumberOfPlayers:  numberOfPlayers,
@@ -74,6 +118,7 @@ func CreateGame(maxQuestions int, language Language, numberOfPlayers int, topic
 			CreatedTimestamp: time.Now().Unix(),
 			Questions:        questions,
 			Scores:           make(map[string][]int),
+			Participants:     playerIDs,
 		}
 		dataStr, err := json.Marshal(data)
 		if err != nil {
@@ -85,74 +130,71 @@ func CreateGame(maxQuestions int, language Language, numberOfPlayers int, topic
 		if err == nil {
 			_, err := database.RedisClient.Set(LastGameIDKey, gameID, 0).Result()
 			if err == nil {
-				return strconv.Itoa(gameID), nil
+				RecordSeenQuestions(playerIDs, questions)
+				AddActiveGame(data.ID)
+				PublishGameEvent(GameEvent{Type: EventCreated, GameID: data.ID})
+				return data.ID, nil
 			}
 		}
 	}
 	return "error", errors.New("Error while creating game for the user")
 }
 
-//GetGameQuestions get game questions
-func GetGameQuestions(topic Topic, language Language, numOfQuestions int) ([]Question, error) {
-	randomScoreQuery := map[string]interface{}{
-		"random_score": map[string]interface{}{},
+//newGameID allocates the id of a game whose questions are chosen by the caller
+func newGameID() (string, error) {
+	gameID, err := database.RedisClient.Incr(LastGameIDKey).Result()
+	if err != nil {
+		return "", err
 	}
-	functionsMap := []map[string]interface{}{randomScoreQuery}
+	return strconv.FormatInt(gameID, 10), nil
+}
 
-	topicsArray := []string{strings.ToLower(topic.String())}
-	topicsQuery := map[string]interface{}{
-		"terms": map[string][]string{
-			"topics": topicsArray,
-		},
-	}
+func gameFinishedKey(gameID string) string {
+	return "game-finished-" + gameID
+}
 
-	languageQuery := map[string]interface{}{
-		"term": map[string]string{
-			"language": strings.ToLower(language.String()),
-		},
+//GameFinished records the outcome of a finished game once, voided games are
+//not recorded
+func GameFinished(game *Game) {
+	first, err := database.RedisClient.SetNX(gameFinishedKey(game.ID), true, 0).Result()
+	if err != nil {
+		log.Println("error while recording the outcome of game "+game.ID, err)
+		return
 	}
-
-	filterQuery := []map[string]interface{}{topicsQuery, languageQuery}
-
-	query := map[string]interface{}{
-		"size": numOfQuestions + 1,
-		"query": map[string]interface{}{
-			"bool": map[string]interface{}{
-				"filter": filterQuery,
-				"must": map[string]interface{}{
-					"function_score": map[string]interface{}{
-						"functions": functionsMap,
-					},
-				},
-			},
-		},
+	if !first {
+		return
 	}
-
-	result, err := database.SearchQuestions(query)
-	questions := make([]Question, numOfQuestions+1)
-	hits := result["hits"].([]interface{})
-	for i, hit := range hits {
-		questionObject := hit.(map[string]interface{})["_source"].(map[string]interface{})
-		question := Question{
-			QuestionText:  questionObject["question_text"].(string),
-			Answer:        questionObject["answer"].(string),
-			Options:       getOptionsArray(questionObject["options"].([]interface{})),
-			PlayerAnswers: make(map[string]string),
-		}
-		questions[i] = question
+	RemoveActiveGame(game.ID)
+	for playerID := range game.Players {
+		SetPresence(playerID, Online)
 	}
-
-	if err != nil {
-		return nil, err
+	TournamentGameFinished(game)
+	if game.Voided {
+		return
+	}
+	RecordQuestionStats(game)
+	if game.Mode == ModeDaily {
+		RecordDailyChallengeScore(game)
+		return
 	}
+	RecordPlayerStats(game)
+	RecordLeaderboards(game)
+}
 
-	return questions, nil
+//ShowQuestion marks the current question as shown to the players, answer
+//times are measured from it
+func ShowQuestion(game *Game) {
+	game.QuestionShownAt = time.Now().UnixNano() / int64(time.Millisecond)
 }
 
-func getOptionsArray(optionsInterface []interface{}) []string {
-	optionString := make([]string, len(optionsInterface))
-	for i, option := range optionsInterface {
-		optionString[i] = option.(string)
+//RecordAnswerTime stores the time the player took to answer the current question
+func RecordAnswerTime(game *Game, playerID string) {
+	question := &game.Questions[game.QuestionNumber]
+	if question.AnswerTimes == nil {
+		question.AnswerTimes = make(map[string]int64)
+	}
+	if _, ok := question.AnswerTimes[playerID]; ok || game.QuestionShownAt == 0 {
+		return
 	}
-	return optionString
+	question.AnswerTimes[playerID] = time.Now().UnixNano()/int64(time.Millisecond) - game.QuestionShownAt
 }
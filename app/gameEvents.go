@@ -0,0 +1,85 @@
+package app
+
+import (
+	"encoding/json"
+	"log"
+	"sharequiz/app/database"
+	"time"
+)
+
+//GameEventsChannel redis channel of the game lifecycle events
+const GameEventsChannel = "game-events"
+
+//GameEventLogRetention time the event log of a game is kept after its last event
+const GameEventLogRetention = 90 * 24 * time.Hour
+
+const (
+	//EventCreated game created by matchmaking or a room
+	EventCreated = "created"
+	//EventJoin player joined the game
+	EventJoin = "join"
+	//EventQuestionShown new question sent to the players
+	EventQuestionShown = "question_shown"
+	//EventAnswer answer received from a player
+	EventAnswer = "answer"
+	//EventQuestionClosed every player answered the question
+	EventQuestionClosed = "question_closed"
+	//EventAdminAction admin changed the game, the data holds the action
+	EventAdminAction = "admin_action"
+	//EventReaction player sent a reaction, the data holds the reaction
+	EventReaction = "reaction"
+	//EventLifeline player used a lifeline, the data holds the lifeline
+	EventLifeline = "lifeline"
+	//EventDisconnect player disconnected from the game
+	EventDisconnect = "disconnect"
+	//EventGameOver game finished
+	EventGameOver = "game_over"
+)
+
+//GameEvent something which happened in a game
+type GameEvent struct {
+	Type           string `json:"type"`
+	GameID         string `json:"gameID"`
+	PlayerID       string `json:"playerID,omitempty"`
+	QuestionNumber int    `json:"questionNumber"`
+	Timestamp      int64  `json:"timestamp"`
+	Data           string `json:"data,omitempty"`
+}
+
+func gameEventLogKey(gameID string) string {
+	return "game-events-" + gameID
+}
+
+//PublishGameEvent appends the event to the event log of the game and sends it
+//to the game events channel, the timestamp is set to the current time in
+//milliseconds
+func PublishGameEvent(event GameEvent) {
+	event.Timestamp = time.Now().UnixNano() / int64(time.Millisecond)
+	eventJSON, err := json.Marshal(event)
+	if err != nil {
+		return
+	}
+	pipe := database.RedisClient.Pipeline()
+	pipe.RPush(gameEventLogKey(event.GameID), string(eventJSON))
+	pipe.Expire(gameEventLogKey(event.GameID), GameEventLogRetention)
+	pipe.Publish(GameEventsChannel, string(eventJSON))
+	if _, err := pipe.Exec(); err != nil {
+		log.Println("error while publishing game event", err)
+	}
+}
+
+//GetGameEvents returns the event log of the game in order
+func GetGameEvents(gameID string) ([]GameEvent, error) {
+	values, err := database.RedisClient.LRange(gameEventLogKey(gameID), 0, -1).Result()
+	if err != nil {
+		return nil, err
+	}
+	events := make([]GameEvent, 0, len(values))
+	for _, value := range values {
+		event := GameEvent{}
+		if err := json.Unmarshal([]byte(value), &event); err == nil {
+			events = append(events, event)
+		}
+	}
+	return events, nil
+}
@@ -0,0 +1,105 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis"
+)
+
+//ReplayStep event of a game with the scores after it, the offset is the time
+//since the first event in milliseconds
+type ReplayStep struct {
+	GameEvent
+	Offset int64          `json:"offset"`
+	Scores map[string]int `json:"scores"`
+}
+
+//GameReplay finished game with its events step by step
+type GameReplay struct {
+	Game  *Game        `json:"game"`
+	Steps []ReplayStep `json:"steps"`
+}
+
+//ReplayGame rebuilds the game step by step from its event log, the answers
+//score what the game recorded for the question
+func ReplayGame(gameID string) (*GameReplay, error) {
+	game, err := GetGame(gameID)
+	if err == redis.Nil {
+		return nil, ErrGameNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	events, err := GetGameEvents(gameID)
+	if err != nil {
+		return nil, err
+	}
+	scores := make(map[string]int)
+	steps := make([]ReplayStep, 0, len(events))
+	for _, event := range events {
+		switch event.Type {
+		case EventJoin:
+			if _, ok := scores[event.PlayerID]; !ok {
+				scores[event.PlayerID] = 0
+			}
+		case EventAnswer:
+			if playerScores := game.Scores[event.PlayerID]; event.QuestionNumber < len(playerScores) {
+				scores[event.PlayerID] += playerScores[event.QuestionNumber]
+			}
+		case EventAdminAction:
+			action := GameAdminAction{}
+			if json.Unmarshal([]byte(event.Data), &action) == nil && action.Action == ActionAdjustScore {
+				scores[action.PlayerID] += action.Delta
+			}
+		}
+		step := ReplayStep{GameEvent: event, Offset: event.Timestamp - events[0].Timestamp, Scores: make(map[string]int, len(scores))}
+		for playerID, score := range scores {
+			step.Scores[playerID] = score
+		}
+		steps = append(steps, step)
+	}
+	return &GameReplay{Game: game, Steps: steps}, nil
+}
+
+//hideDailyAnswers removes the answers and explanations of a daily challenge
+//game until the day is over, other players still play the same questions
+func hideDailyAnswers(game *Game) {
+	if game.Mode != ModeDaily || time.Now().Unix() >= game.Deadline {
+		return
+	}
+	for i := range game.Questions {
+		game.Questions[i].Answer = ""
+		game.Questions[i].Explanations = nil
+	}
+}
+
+//GetGameReplay returns the replay of a finished game to its players
+func GetGameReplay(c *gin.Context) {
+	replay, err := ReplayGame(c.Param("id"))
+	if err == nil {
+		if _, ok := replay.Game.Players[CurrentUserID(c)]; !ok {
+			err = ErrGameNotFound
+		}
+	}
+	if err == ErrGameNotFound {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": err.Error(),
+		})
+		return
+	} else if err != nil {
+		sendError(c, "error while getting the replay")
+		return
+	}
+	if replay.Game.Status != Finished {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "the game is not finished",
+		})
+		return
+	}
+	hideDailyAnswers(replay.Game)
+	c.JSON(http.StatusOK, gin.H{
+		"replay": replay,
+	})
+}
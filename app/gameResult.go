@@ -0,0 +1,195 @@
+package app
+
+import (
+	"encoding/json"
+	"sharequiz/app/database"
+	"sort"
+	"time"
+)
+
+const (
+	//ActionForceAdvance admin moved the game to the next question
+	ActionForceAdvance = "force_advance"
+	//ActionForceFinish admin finished the game
+	ActionForceFinish = "force_finish"
+	//ActionVoid admin voided the game, voided games are excluded from stats
+	ActionVoid = "void"
+	//ActionAdjustScore admin changed the score of a player
+	ActionAdjustScore = "adjust_score"
+)
+
+const (
+	//CorrectAnswerScore score of a correct answer
+	CorrectAnswerScore = 10
+	//MaxTimeBonus bonus score of an instant correct answer, it goes down to 0
+	//over AnswerTime
+	MaxTimeBonus = 10
+	//AnswerTime time to answer a question in milliseconds, later answers score 0
+	AnswerTime = 20000
+)
+
+//PlayerResult final result of a player in a game
+type PlayerResult struct {
+	PlayerID  string   `json:"playerID"`
+	Score     int      `json:"score"`
+	Correct   int      `json:"correct"`
+	Answered  int      `json:"answered"`
+	Skipped   int      `json:"skipped"`
+	Lifelines []string `json:"lifelines,omitempty"`
+}
+
+//GameAdminAction admin action recorded on the game
+type GameAdminAction struct {
+	Action    string `json:"action"`
+	Admin     string `json:"admin"`
+	Reason    string `json:"reason"`
+	PlayerID  string `json:"playerID,omitempty"`
+	Delta     int    `json:"delta,omitempty"`
+	Timestamp int64  `json:"timestamp"`
+}
+
+//GetGame loads the game with the id
+func GetGame(gameID string) (*Game, error) {
+	gameData, err := database.RedisClient.Get(gameID).Result()
+	if err != nil {
+		return nil, err
+	}
+	game := &Game{}
+	if err := json.Unmarshal([]byte(gameData), game); err != nil {
+		return nil, err
+	}
+	return game, nil
+}
+
+//SaveGame stores the game and returns its json
+func SaveGame(game *Game) (string, error) {
+	gameJSON, err := json.Marshal(game)
+	if err != nil {
+		return "", err
+	}
+	_, err = database.RedisClient.Set(game.ID, string(gameJSON), 0).Result()
+	return string(gameJSON), err
+}
+
+//AnswerScore score of a correct answer given taken milliseconds after the
+//question was shown, with timeLimit milliseconds to answer
+func AnswerScore(taken int64, timeLimit int64) int {
+	if taken > timeLimit {
+		return 0
+	}
+	if taken < 0 {
+		taken = 0
+	}
+	return CorrectAnswerScore + int(int64(MaxTimeBonus)*(timeLimit-taken)/timeLimit)
+}
+
+//answerTimeLimit time the player had to answer the question, the extra time
+//lifeline adds ExtraTime
+func answerTimeLimit(game *Game, playerID string, number int) int64 {
+	if usedLifeline(game, playerID, number) == LifelineExtraTime {
+		return AnswerTime + ExtraTime
+	}
+	return AnswerTime
+}
+
+//ScoreQuestion sets the scores of the players for a question of a live game
+//from the answers graded by the server and the answer times measured by the
+//server, the scores sent by the clients are never used. Answers after the
+//time limit, extended by the extra time lifeline, score nothing.
+func ScoreQuestion(game *Game, number int) {
+	if number < 1 || number >= len(game.Questions) {
+		return
+	}
+	question := game.Questions[number]
+	correct := question.CorrectPlayers()
+	for playerID := range game.Players {
+		scores := game.Scores[playerID]
+		if len(scores) <= number {
+			scores = append(scores, make([]int, game.MaxQuestions+1-len(scores))...)
+			game.Scores[playerID] = scores
+		}
+		scores[number] = 0
+		if !correct[playerID] || game.Skipped(playerID, number) {
+			continue
+		}
+		timeLimit := answerTimeLimit(game, playerID, number)
+		taken, ok := question.AnswerTimes[playerID]
+		if !ok {
+			taken = timeLimit
+		}
+		scores[number] = AnswerScore(taken, timeLimit)
+	}
+}
+
+//ComputeResults computes the results of the players from their per question
+//scores, their answers and the score adjustments, highest score first
+func ComputeResults(game *Game) []PlayerResult {
+	results := make([]PlayerResult, 0, len(game.Players))
+	correct := make([]map[string]bool, len(game.Questions))
+	for i, question := range game.Questions {
+		correct[i] = question.CorrectPlayers()
+	}
+	for playerID := range game.Players {
+		result := PlayerResult{PlayerID: playerID}
+		for _, score := range game.Scores[playerID] {
+			result.Score += score
+		}
+		for i, question := range game.Questions {
+			if _, ok := question.PlayerAnswers[playerID]; i == 0 || !ok {
+				continue
+			}
+			if game.Skipped(playerID, i) {
+				result.Skipped++
+				continue
+			}
+			result.Answered++
+			if correct[i][playerID] {
+				result.Correct++
+			}
+		}
+		for _, use := range game.Lifelines[playerID] {
+			result.Lifelines = append(result.Lifelines, use.Lifeline)
+		}
+		for _, action := range game.AdminActions {
+			if action.Action == ActionAdjustScore && action.PlayerID == playerID {
+				result.Score += action.Delta
+			}
+		}
+		results = append(results, result)
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].PlayerID < results[j].PlayerID
+	})
+	return results
+}
+
+//FinishGame marks the game finished and stores its results, the winner is
+//empty on a draw. The current question of a live game is scored first.
+func FinishGame(game *Game) {
+	if game.Mode == ModeLive && game.Status != Finished {
+		ScoreQuestion(game, game.QuestionNumber)
+	}
+	game.Status = Finished
+	game.Results = ComputeResults(game)
+	game.Winner = ""
+	if len(game.Results) > 0 && (len(game.Results) == 1 || game.Results[0].Score > game.Results[1].Score) {
+		game.Winner = game.Results[0].PlayerID
+	}
+}
+
+//AddAdminAction records the admin action on the game
+func AddAdminAction(game *Game, action GameAdminAction) {
+	action.Timestamp = time.Now().Unix()
+	game.AdminActions = append(game.AdminActions, action)
+	actionJSON, _ := json.Marshal(action)
+	PublishGameEvent(GameEvent{
+		Type:           EventAdminAction,
+		GameID:         game.ID,
+		PlayerID:       action.PlayerID,
+		QuestionNumber: game.QuestionNumber,
+		Data:           string(actionJSON),
+	})
+}
@@ -0,0 +1,58 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+)
+
+//Fit returns the size of the image scaled to fit in a square of the size,
+//keeping the aspect ratio
+func Fit(bounds image.Rectangle, size int) (int, int) {
+	width, height := bounds.Dx(), bounds.Dy()
+	if width >= height {
+		return size, max(1, height*size/width)
+	}
+	return max(1, width*size/height), size
+}
+
+//Resize scales the image to width x height averaging the source pixels
+//covered by each target pixel, colors are averaged with premultiplied alpha
+func Resize(src image.Image, width int, height int) *image.NRGBA {
+	bounds := src.Bounds()
+	srcWidth, srcHeight := bounds.Dx(), bounds.Dy()
+	dst := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		y0 := y * srcHeight / height
+		y1 := max(y0+1, (y+1)*srcHeight/height)
+		for x := 0; x < width; x++ {
+			x0 := x * srcWidth / width
+			x1 := max(x0+1, (x+1)*srcWidth/width)
+			var r, g, b, a, count uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					pr, pg, pb, pa := src.At(bounds.Min.X+sx, bounds.Min.Y+sy).RGBA()
+					r += uint64(pr)
+					g += uint64(pg)
+					b += uint64(pb)
+					a += uint64(pa)
+					count++
+				}
+			}
+			average := color.RGBA64{
+				R: uint16(r / count),
+				G: uint16(g / count),
+				B: uint16(b / count),
+				A: uint16(a / count),
+			}
+			dst.Set(x, y, color.NRGBAModel.Convert(average))
+		}
+	}
+	return dst
+}
+
+func max(a int, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,290 @@
+package imaging
+
+import (
+	"container/heap"
+	"encoding/binary"
+	"errors"
+	"image"
+	"io"
+)
+
+const (
+	maxWebPSize       = 1 << 14
+	maxCodeLength     = 15
+	maxCodeLengthBits = 7
+	greenAlphabetSize = 256 + 24
+	distanceAlphabet  = 40
+)
+
+//codeLengthOrder order in which the lengths of the code length code are written
+var codeLengthOrder = []int{17, 18, 0, 1, 2, 3, 4, 5, 16, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15}
+
+//EncodeWebP writes the image as a lossless webp. Every pixel is written as a
+//literal with one set of prefix codes, which keeps the encoder small while
+//still compressing the flat colors of icons and artwork.
+func EncodeWebP(w io.Writer, img *image.NRGBA) error {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width < 1 || height < 1 || width > maxWebPSize || height > maxWebPSize {
+		return errors.New("webp images are 1 to 16384 pixels wide and high")
+	}
+
+	green := make([]int, greenAlphabetSize)
+	red := make([]int, 256)
+	blue := make([]int, 256)
+	alpha := make([]int, 256)
+	alphaUsed := false
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			pixel := img.NRGBAAt(x, y)
+			green[pixel.G]++
+			red[pixel.R]++
+			blue[pixel.B]++
+			alpha[pixel.A]++
+			alphaUsed = alphaUsed || pixel.A != 0xff
+		}
+	}
+
+	bits := &bitWriter{}
+	bits.write(0x2f, 8)
+	bits.write(uint32(width-1), 14)
+	bits.write(uint32(height-1), 14)
+	if alphaUsed {
+		bits.write(1, 1)
+	} else {
+		bits.write(0, 1)
+	}
+	bits.write(0, 3)
+	//no transforms, no color cache and no meta prefix codes
+	bits.write(0, 1)
+	bits.write(0, 1)
+	bits.write(0, 1)
+
+	greenLengths, greenCodes := writePrefixCode(bits, green)
+	redLengths, redCodes := writePrefixCode(bits, red)
+	blueLengths, blueCodes := writePrefixCode(bits, blue)
+	alphaLengths, alphaCodes := writePrefixCode(bits, alpha)
+	writePrefixCode(bits, make([]int, distanceAlphabet))
+
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			pixel := img.NRGBAAt(x, y)
+			bits.write(uint32(greenCodes[pixel.G]), uint(greenLengths[pixel.G]))
+			bits.write(uint32(redCodes[pixel.R]), uint(redLengths[pixel.R]))
+			bits.write(uint32(blueCodes[pixel.B]), uint(blueLengths[pixel.B]))
+			bits.write(uint32(alphaCodes[pixel.A]), uint(alphaLengths[pixel.A]))
+		}
+	}
+	data := bits.bytes()
+
+	padding := len(data) % 2
+	header := make([]byte, 20)
+	copy(header[0:], "RIFF")
+	binary.LittleEndian.PutUint32(header[4:], uint32(12+len(data)+padding))
+	copy(header[8:], "WEBPVP8L")
+	binary.LittleEndian.PutUint32(header[16:], uint32(len(data)))
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+	if _, err := w.Write(data); err != nil {
+		return err
+	}
+	if padding == 1 {
+		_, err := w.Write([]byte{0})
+		return err
+	}
+	return nil
+}
+
+//writePrefixCode writes a normal prefix code for the symbol counts and returns
+//the code lengths and the bit reversed codes of the symbols
+func writePrefixCode(bits *bitWriter, counts []int) ([]uint8, []uint16) {
+	lengths := codeLengths(counts, maxCodeLength)
+	lengthCounts := make([]int, len(codeLengthOrder))
+	for _, length := range lengths {
+		lengthCounts[length]++
+	}
+	lengthCodeLengths := codeLengths(lengthCounts, maxCodeLengthBits)
+	lengthCodes := canonicalCodes(lengthCodeLengths)
+
+	numCodeLengths := 4
+	for i, symbol := range codeLengthOrder {
+		if lengthCodeLengths[symbol] != 0 && i+1 > numCodeLengths {
+			numCodeLengths = i + 1
+		}
+	}
+	bits.write(0, 1)
+	bits.write(uint32(numCodeLengths-4), 4)
+	for _, symbol := range codeLengthOrder[:numCodeLengths] {
+		bits.write(uint32(lengthCodeLengths[symbol]), 3)
+	}
+	//the code lengths of all the symbols follow
+	bits.write(0, 1)
+	for _, length := range lengths {
+		bits.write(uint32(lengthCodes[length]), uint(lengthCodeLengths[length]))
+	}
+	return lengths, canonicalCodes(lengths)
+}
+
+//codeLengths returns huffman code lengths of at most maxLength bits for the
+//symbol counts. At least two symbols get a code so that every code is complete.
+func codeLengths(counts []int, maxLength int) []uint8 {
+	weights := make([]int, len(counts))
+	copy(weights, counts)
+	used := 0
+	for _, weight := range weights {
+		if weight > 0 {
+			used++
+		}
+	}
+	for i := 0; used < 2 && i < len(weights); i++ {
+		if weights[i] == 0 {
+			weights[i] = 1
+			used++
+		}
+	}
+	for {
+		lengths := huffmanLengths(weights)
+		longest := uint8(0)
+		for _, length := range lengths {
+			if length > longest {
+				longest = length
+			}
+		}
+		if int(longest) <= maxLength {
+			return lengths
+		}
+		//flatten the weights until the tree is shallow enough
+		for i, weight := range weights {
+			if weight > 0 {
+				weights[i] = weight/2 + 1
+			}
+		}
+	}
+}
+
+//huffmanNode leaf of a symbol when left is -1, otherwise an inner node
+type huffmanNode struct {
+	weight int
+	order  int
+	symbol int
+	left   int
+	right  int
+}
+
+type huffmanHeap struct {
+	nodes []huffmanNode
+	queue []int
+}
+
+func (h *huffmanHeap) Len() int { return len(h.queue) }
+func (h *huffmanHeap) Less(i, j int) bool {
+	a, b := h.nodes[h.queue[i]], h.nodes[h.queue[j]]
+	if a.weight != b.weight {
+		return a.weight < b.weight
+	}
+	return a.order < b.order
+}
+func (h *huffmanHeap) Swap(i, j int)      { h.queue[i], h.queue[j] = h.queue[j], h.queue[i] }
+func (h *huffmanHeap) Push(x interface{}) { h.queue = append(h.queue, x.(int)) }
+func (h *huffmanHeap) Pop() interface{} {
+	last := h.queue[len(h.queue)-1]
+	h.queue = h.queue[:len(h.queue)-1]
+	return last
+}
+
+//huffmanLengths returns the depth of every symbol in a huffman tree of the weights
+func huffmanLengths(weights []int) []uint8 {
+	h := &huffmanHeap{}
+	for symbol, weight := range weights {
+		if weight > 0 {
+			h.nodes = append(h.nodes, huffmanNode{weight: weight, order: symbol, symbol: symbol, left: -1})
+			h.queue = append(h.queue, len(h.nodes)-1)
+		}
+	}
+	heap.Init(h)
+	for h.Len() > 1 {
+		a := heap.Pop(h).(int)
+		b := heap.Pop(h).(int)
+		h.nodes = append(h.nodes, huffmanNode{
+			weight: h.nodes[a].weight + h.nodes[b].weight,
+			order:  len(weights) + len(h.nodes),
+			left:   a,
+			right:  b,
+		})
+		heap.Push(h, len(h.nodes)-1)
+	}
+	lengths := make([]uint8, len(weights))
+	var walk func(node int, depth uint8)
+	walk = func(node int, depth uint8) {
+		if h.nodes[node].left < 0 {
+			lengths[h.nodes[node].symbol] = depth
+			return
+		}
+		walk(h.nodes[node].left, depth+1)
+		walk(h.nodes[node].right, depth+1)
+	}
+	walk(len(h.nodes)-1, 0)
+	return lengths
+}
+
+//canonicalCodes assigns the canonical codes of the lengths, bit reversed as
+//the codes are read starting with their most significant bit
+func canonicalCodes(lengths []uint8) []uint16 {
+	var lengthCount [maxCodeLength + 1]int
+	for _, length := range lengths {
+		if length > 0 {
+			lengthCount[length]++
+		}
+	}
+	var nextCode [maxCodeLength + 1]int
+	code := 0
+	for length := 1; length <= maxCodeLength; length++ {
+		code = (code + lengthCount[length-1]) << 1
+		nextCode[length] = code
+	}
+	codes := make([]uint16, len(lengths))
+	for symbol, length := range lengths {
+		if length == 0 {
+			continue
+		}
+		codes[symbol] = reverseBits(uint16(nextCode[length]), length)
+		nextCode[length]++
+	}
+	return codes
+}
+
+func reverseBits(code uint16, length uint8) uint16 {
+	reversed := uint16(0)
+	for i := uint8(0); i < length; i++ {
+		reversed = reversed<<1 | code&1
+		code >>= 1
+	}
+	return reversed
+}
+
+//bitWriter packs bits starting with the least significant bit
+type bitWriter struct {
+	data  []byte
+	value uint64
+	count uint
+}
+
+func (w *bitWriter) write(value uint32, bits uint) {
+	w.value |= uint64(value) << w.count
+	w.count += bits
+	for w.count >= 8 {
+		w.data = append(w.data, byte(w.value))
+		w.value >>= 8
+		w.count -= 8
+	}
+}
+
+func (w *bitWriter) bytes() []byte {
+	if w.count > 0 {
+		w.data = append(w.data, byte(w.value))
+		w.value = 0
+		w.count = 0
+	}
+	return w.data
+}
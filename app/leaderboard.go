@@ -0,0 +1,301 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sharequiz/app/database"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis"
+)
+
+//Leaderboard windows
+const (
+	AllTime = "all_time"
+	Weekly  = "weekly"
+	Daily   = "daily"
+)
+
+const (
+	//DefaultLeaderboardSize entries returned from the top of a leaderboard
+	DefaultLeaderboardSize = 10
+	//MaxLeaderboardSize most entries returned from the top of a leaderboard
+	MaxLeaderboardSize = 100
+	//LeaderboardNeighbors players shown above and below the caller
+	LeaderboardNeighbors = 2
+	//LeaderboardRetention time a finished daily or weekly board is kept
+	LeaderboardRetention = 14 * 24 * time.Hour
+
+	globalScope = "global"
+)
+
+//LeaderboardEntry ranked player of a leaderboard, ranks start at 1
+type LeaderboardEntry struct {
+	Rank        int64   `json:"rank"`
+	UserID      string  `json:"userID"`
+	DisplayName string  `json:"displayName"`
+	Avatar      string  `json:"avatar"`
+	Score       float64 `json:"score"`
+}
+
+//Leaderboard top of a leaderboard with the caller and the players around them
+type Leaderboard struct {
+	Window    string             `json:"window"`
+	Period    string             `json:"period"`
+	Scope     string             `json:"scope"`
+	Top       []LeaderboardEntry `json:"top"`
+	Me        *LeaderboardEntry  `json:"me,omitempty"`
+	Neighbors []LeaderboardEntry `json:"neighbors"`
+}
+
+//periodID identifies the period of the window the time falls in
+func periodID(window string, t time.Time) string {
+	t = t.UTC()
+	switch window {
+	case Daily:
+		return t.Format("2006-01-02")
+	case Weekly:
+		year, week := t.ISOWeek()
+		return fmt.Sprintf("%d-W%02d", year, week)
+	}
+	return "all"
+}
+
+func leaderboardKey(window string, period string, scope string) string {
+	return "leaderboard-" + window + "-" + period + "-" + scope
+}
+
+func topicScope(topic Topic) string {
+	return "topic-" + strconv.Itoa(int(topic))
+}
+
+func languageScope(language Language) string {
+	return "language-" + strconv.Itoa(int(language))
+}
+
+//RecordLeaderboards adds the scores of the finished game to the global, topic
+//and language boards of every window
+func RecordLeaderboards(game *Game) {
+	now := time.Now()
+	pipe := database.RedisClient.Pipeline()
+	for _, window := range []string{AllTime, Weekly, Daily} {
+		period := periodID(window, now)
+		for _, scope := range []string{globalScope, topicScope(game.Topic), languageScope(game.Language)} {
+			for _, result := range game.Results {
+				pipe.ZIncrBy(leaderboardKey(window, period, scope), float64(result.Score), result.PlayerID)
+			}
+		}
+	}
+	if _, err := pipe.Exec(); err != nil {
+		log.Println("error while updating the leaderboards of game "+game.ID, err)
+	}
+}
+
+//GetLeaderboard returns the top of the board and the rank of the user with
+//their neighbors
+func GetLeaderboard(window string, period string, scope string, size int, userID string) (*Leaderboard, error) {
+	key := leaderboardKey(window, period, scope)
+	leaderboard := &Leaderboard{Window: window, Period: period, Scope: scope}
+	top, err := database.RedisClient.ZRevRangeWithScores(key, 0, int64(size-1)).Result()
+	if err != nil {
+		return nil, err
+	}
+	leaderboard.Top = leaderboardEntries(top, 1)
+	leaderboard.Neighbors = make([]LeaderboardEntry, 0)
+	rank, err := database.RedisClient.ZRevRank(key, userID).Result()
+	if err == redis.Nil {
+		return leaderboard, nil
+	} else if err != nil {
+		return nil, err
+	}
+	start := rank - LeaderboardNeighbors
+	if start < 0 {
+		start = 0
+	}
+	around, err := database.RedisClient.ZRevRangeWithScores(key, start, rank+LeaderboardNeighbors).Result()
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range leaderboardEntries(around, start+1) {
+		if entry.UserID == userID {
+			me := entry
+			leaderboard.Me = &me
+			continue
+		}
+		leaderboard.Neighbors = append(leaderboard.Neighbors, entry)
+	}
+	return leaderboard, nil
+}
+
+func leaderboardEntries(members []redis.Z, firstRank int64) []LeaderboardEntry {
+	entries := make([]LeaderboardEntry, 0, len(members))
+	userIDs := make([]string, len(members))
+	for i, member := range members {
+		userIDs[i], _ = member.Member.(string)
+	}
+	profiles, err := GetPublicProfiles(userIDs)
+	if err != nil {
+		log.Println("error while loading the leaderboard profiles", err)
+	}
+	for i, member := range members {
+		entry := LeaderboardEntry{Rank: firstRank + int64(i), UserID: userIDs[i], Score: member.Score}
+		if profile, ok := profiles[userIDs[i]]; ok {
+			entry.DisplayName = profile.DisplayName
+			entry.Avatar = profile.Avatar
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+//GetFriendsLeaderboard ranks the user and their friends on the board
+func GetFriendsLeaderboard(window string, period string, scope string, userID string) (*Leaderboard, error) {
+	friendIDs, err := GetFriendIDs(userID)
+	if err != nil {
+		return nil, err
+	}
+	userIDs := append(friendIDs, userID)
+	key := leaderboardKey(window, period, scope)
+	pipe := database.RedisClient.Pipeline()
+	scores := make([]*redis.FloatCmd, len(userIDs))
+	for i, id := range userIDs {
+		scores[i] = pipe.ZScore(key, id)
+	}
+	if _, err := pipe.Exec(); err != nil && err != redis.Nil {
+		return nil, err
+	}
+	members := make([]redis.Z, 0, len(userIDs))
+	for i, id := range userIDs {
+		if score, err := scores[i].Result(); err == nil {
+			members = append(members, redis.Z{Score: score, Member: id})
+		}
+	}
+	sort.Slice(members, func(i, j int) bool {
+		return members[i].Score > members[j].Score
+	})
+	leaderboard := &Leaderboard{Window: window, Period: period, Scope: scope}
+	leaderboard.Top = leaderboardEntries(members, 1)
+	leaderboard.Neighbors = make([]LeaderboardEntry, 0)
+	for _, entry := range leaderboard.Top {
+		if entry.UserID == userID {
+			me := entry
+			leaderboard.Me = &me
+		}
+	}
+	return leaderboard, nil
+}
+
+//GetLeaderboardHandler returns a leaderboard, window is all_time, weekly or
+//daily and the board is global unless a topic or a language is given.
+//previous=true returns the board of the last finished period and
+//friends=true ranks only the friends of the caller.
+func GetLeaderboardHandler(c *gin.Context) {
+	window := c.DefaultQuery("window", AllTime)
+	if window != AllTime && window != Weekly && window != Daily {
+		sendError(c, "unknown window")
+		return
+	}
+	scope, err := leaderboardScope(c.Query("topic"), c.Query("language"))
+	if err != nil {
+		sendError(c, err.Error())
+		return
+	}
+	now := time.Now()
+	if c.Query("previous") == "true" {
+		now = previousPeriod(window, now)
+	}
+	size, err := strconv.Atoi(c.Query("size"))
+	if err != nil || size < 1 {
+		size = DefaultLeaderboardSize
+	}
+	if size > MaxLeaderboardSize {
+		size = MaxLeaderboardSize
+	}
+	var leaderboard *Leaderboard
+	if c.Query("friends") == "true" {
+		leaderboard, err = GetFriendsLeaderboard(window, periodID(window, now), scope, CurrentUserID(c))
+	} else {
+		leaderboard, err = GetLeaderboard(window, periodID(window, now), scope, size, CurrentUserID(c))
+	}
+	if err != nil {
+		sendError(c, "error while getting the leaderboard")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"leaderboard": leaderboard,
+	})
+}
+
+func leaderboardScope(topicParam string, languageParam string) (string, error) {
+	if topicParam != "" && languageParam != "" {
+		return "", errors.New("choose a topic or a language")
+	}
+	if topicParam != "" {
+		topic, err := strconv.Atoi(topicParam)
+		if _, ok := GetTopic(Topic(topic)); err != nil || !ok {
+			return "", errors.New("unknown topic")
+		}
+		return topicScope(Topic(topic)), nil
+	}
+	if languageParam != "" {
+		language, err := strconv.Atoi(languageParam)
+		if _, ok := GetLanguage(Language(language)); err != nil || !ok {
+			return "", errors.New("unknown language")
+		}
+		return languageScope(Language(language)), nil
+	}
+	return globalScope, nil
+}
+
+func previousPeriod(window string, t time.Time) time.Time {
+	switch window {
+	case Daily:
+		return t.AddDate(0, 0, -1)
+	case Weekly:
+		return t.AddDate(0, 0, -7)
+	}
+	return t
+}
+
+func leaderboardScopes() []string {
+	scopes := []string{globalScope}
+	for _, topic := range GetTopics(false) {
+		scopes = append(scopes, topicScope(topic.ID))
+	}
+	for _, language := range GetLanguages(false) {
+		scopes = append(scopes, languageScope(language.ID))
+	}
+	return scopes
+}
+
+//RolloverLeaderboards starts new daily and weekly periods, the boards of the
+//finished periods expire after LeaderboardRetention
+func RolloverLeaderboards() {
+	now := time.Now()
+	for _, window := range []string{Weekly, Daily} {
+		period := periodID(window, now)
+		lastPeriod, err := database.RedisClient.GetSet("leaderboard-current-"+window, period).Result()
+		if err != nil || lastPeriod == period {
+			continue
+		}
+		for _, scope := range leaderboardScopes() {
+			database.RedisClient.Expire(leaderboardKey(window, lastPeriod, scope), LeaderboardRetention)
+		}
+		log.Println("leaderboard " + window + " rolled over to " + period)
+	}
+}
+
+//StartLeaderboardRollover rolls the periodic leaderboards over at every interval
+func StartLeaderboardRollover(interval time.Duration) {
+	RolloverLeaderboards()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		RolloverLeaderboards()
+	}
+}
@@ -0,0 +1,128 @@
+package app
+
+import (
+	"errors"
+	"math/rand"
+)
+
+//Lifelines a player can use during a game
+const (
+	LifelineFiftyFifty = "fifty_fifty"
+	LifelineExtraTime  = "extra_time"
+	LifelineSkip       = "skip"
+)
+
+//ExtraTime time added to the question by the extra time lifeline in milliseconds
+const ExtraTime = 10000
+
+//LifelineAllowance times each lifeline can be used by a player in a game
+var LifelineAllowance = map[string]int{
+	LifelineFiftyFifty: 1,
+	LifelineExtraTime:  1,
+	LifelineSkip:       1,
+}
+
+//Errors of the lifelines
+var (
+	ErrUnknownLifeline  = errors.New("unknown lifeline")
+	ErrLifelineUsedUp   = errors.New("lifeline already used")
+	ErrLifelineQuestion = errors.New("lifeline cannot be used on this question")
+)
+
+//LifelineData lifeline chosen by the player
+type LifelineData struct {
+	Lifeline string `json:"lifeline"`
+}
+
+//LifelineUse lifeline used by a player on a question, the remaining options
+//of a 50:50 are sent only to that player and never stored on the game
+type LifelineUse struct {
+	Lifeline       string   `json:"lifeline"`
+	QuestionNumber int      `json:"questionNumber"`
+	Options        []string `json:"options,omitempty"`
+	ExtraTime      int64    `json:"extraTime,omitempty"`
+}
+
+//UseLifeline applies the lifeline of the player to the question. 50:50 keeps
+//the answer and one random wrong option, extra time gives ExtraTime more to
+//answer and skip answers the question without counting it. A player can use
+//one lifeline per question.
+func UseLifeline(game *Game, playerID string, lifeline string, questionNumber int) (*LifelineUse, error) {
+	allowance, ok := LifelineAllowance[lifeline]
+	if !ok {
+		return nil, ErrUnknownLifeline
+	}
+	if questionNumber < 1 || questionNumber >= len(game.Questions) {
+		return nil, ErrLifelineQuestion
+	}
+	used := 0
+	for _, use := range game.Lifelines[playerID] {
+		if use.QuestionNumber == questionNumber {
+			return nil, ErrLifelineQuestion
+		}
+		if use.Lifeline == lifeline {
+			used++
+		}
+	}
+	if used >= allowance {
+		return nil, ErrLifelineUsedUp
+	}
+	question := &game.Questions[questionNumber]
+	if _, answered := question.PlayerAnswers[playerID]; answered {
+		return nil, ErrLifelineQuestion
+	}
+	use := LifelineUse{Lifeline: lifeline, QuestionNumber: questionNumber}
+	switch lifeline {
+	case LifelineFiftyFifty:
+		if question.Type == MultiSelectQuestion || question.Type == NumericQuestion {
+			return nil, ErrLifelineQuestion
+		}
+		wrong := make([]string, 0, len(question.Options))
+		for _, option := range question.Options {
+			if option != question.Answer {
+				wrong = append(wrong, option)
+			}
+		}
+		if len(wrong) < 2 {
+			return nil, ErrLifelineQuestion
+		}
+		kept := wrong[rand.Intn(len(wrong))]
+		for _, option := range question.Options {
+			if option == question.Answer || option == kept {
+				use.Options = append(use.Options, option)
+			}
+		}
+	case LifelineExtraTime:
+		use.ExtraTime = ExtraTime
+	case LifelineSkip:
+		if question.PlayerAnswers == nil {
+			question.PlayerAnswers = make(map[string]string)
+		}
+		question.PlayerAnswers[playerID] = ""
+		if scores := game.Scores[playerID]; questionNumber < len(scores) {
+			scores[questionNumber] = 0
+		}
+	}
+	if game.Lifelines == nil {
+		game.Lifelines = make(map[string][]LifelineUse)
+	}
+	stored := use
+	stored.Options = nil
+	game.Lifelines[playerID] = append(game.Lifelines[playerID], stored)
+	return &use, nil
+}
+
+//usedLifeline returns the lifeline the player used on the question
+func usedLifeline(game *Game, playerID string, questionNumber int) string {
+	for _, use := range game.Lifelines[playerID] {
+		if use.QuestionNumber == questionNumber {
+			return use.Lifeline
+		}
+	}
+	return ""
+}
+
+//Skipped tells if the player skipped the question
+func (g *Game) Skipped(playerID string, questionNumber int) bool {
+	return usedLifeline(g, playerID, questionNumber) == LifelineSkip
+}
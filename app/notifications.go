@@ -0,0 +1,94 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+	"sharequiz/app/database"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+//NotificationsChannel redis channel the notifications are published on for
+//delivery to the connected players
+const NotificationsChannel = "notifications"
+
+//MaxNotifications notifications kept per player
+const MaxNotifications = 100
+
+//Notification types
+const (
+	NotificationFriendRequest  = "friend_request"
+	NotificationFriendAccepted = "friend_accepted"
+	NotificationChallenge      = "challenge"
+)
+
+//Notification in-app notification of a player
+type Notification struct {
+	ID               string            `json:"id"`
+	UserID           string            `json:"userID"`
+	Type             string            `json:"type"`
+	From             *PublicProfile    `json:"from,omitempty"`
+	Data             map[string]string `json:"data,omitempty"`
+	CreatedTimestamp int64             `json:"createdTimestamp"`
+}
+
+func notificationsKey(userID string) string {
+	return "notifications-" + userID
+}
+
+//Notify stores the notification for the player and publishes it for the
+//connected clients
+func Notify(userID string, notificationType string, fromUserID string, data map[string]string) error {
+	notification := Notification{
+		ID:               strconv.FormatInt(time.Now().UnixNano(), 36),
+		UserID:           userID,
+		Type:             notificationType,
+		Data:             data,
+		CreatedTimestamp: time.Now().Unix(),
+	}
+	if fromUserID != "" {
+		if from, err := GetPublicProfile(fromUserID); err == nil {
+			notification.From = &from
+		}
+	}
+	notificationJSON, err := json.Marshal(notification)
+	if err != nil {
+		return err
+	}
+	pipe := database.RedisClient.Pipeline()
+	pipe.LPush(notificationsKey(userID), string(notificationJSON))
+	pipe.LTrim(notificationsKey(userID), 0, MaxNotifications-1)
+	pipe.Publish(NotificationsChannel, string(notificationJSON))
+	_, err = pipe.Exec()
+	return err
+}
+
+//GetNotifications returns the notifications of the player, newest first
+func GetNotifications(userID string) ([]Notification, error) {
+	values, err := database.RedisClient.LRange(notificationsKey(userID), 0, -1).Result()
+	if err != nil {
+		return nil, err
+	}
+	notifications := make([]Notification, 0, len(values))
+	for _, value := range values {
+		notification := Notification{}
+		if err := json.Unmarshal([]byte(value), &notification); err == nil {
+			notifications = append(notifications, notification)
+		}
+	}
+	return notifications, nil
+}
+
+//GetMyNotifications returns the notifications of the logged in player
+func GetMyNotifications(c *gin.Context) {
+	notifications, err := GetNotifications(CurrentUserID(c))
+	if err != nil {
+		sendError(c, "error while getting the notifications")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"notifications": notifications,
+	})
+}
@@ -3,6 +3,7 @@ package app
 import (
 	"encoding/json"
 	"fmt"
+	"log"
 	"math/rand"
 	"net/http"
 	"sharequiz/app/database"
@@ -170,8 +171,27 @@ func VerifyOTP(c *gin.Context) {
 				})
 				return
 			}
+			userID, err := GetOrCreateUserID(phoneNumber)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"message": "Error while verifying OTP.",
+				})
+				return
+			}
+			if err := RegisterPhoneHash(phoneNumber, userID); err != nil && err != ErrDiscoveryDisabled {
+				log.Println("phone number of player "+userID+" is not discoverable", err)
+			}
+			token, err := CreateSession(userID)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"message": "Error while verifying OTP.",
+				})
+				return
+			}
 			c.JSON(http.StatusOK, gin.H{
 				"message": "verified",
+				"userID":  userID,
+				"token":   token,
 			})
 			fmt.Println("Otp verified for phoneNumber " + phoneNumber + " is : " + otp)
 		} else {
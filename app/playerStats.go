@@ -0,0 +1,327 @@
+package app
+
+import (
+	"net/http"
+	"sharequiz/app/database"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	//MatchHistoryLimit games kept in the match history of a player
+	MatchHistoryLimit = 200
+	//DefaultHistoryPageSize games in a page of the match history
+	DefaultHistoryPageSize = 20
+	//MaxHistoryPageSize largest page of the match history
+	MaxHistoryPageSize = 50
+
+	resultWon  = "won"
+	resultLost = "lost"
+	resultDraw = "draw"
+)
+
+//TopicStats stats of a player for a topic
+type TopicStats struct {
+	Topic    Topic   `json:"topic,string"`
+	Games    int64   `json:"games"`
+	Won      int64   `json:"won"`
+	Answered int64   `json:"answered"`
+	Correct  int64   `json:"correct"`
+	Accuracy float64 `json:"accuracy"`
+}
+
+//PlayerStats stats of a player over the finished games, streaks count
+//consecutive wins
+type PlayerStats struct {
+	UserID            string       `json:"userID"`
+	Games             int64        `json:"games"`
+	Won               int64        `json:"won"`
+	Lost              int64        `json:"lost"`
+	Drawn             int64        `json:"drawn"`
+	Answered          int64        `json:"answered"`
+	Correct           int64        `json:"correct"`
+	Accuracy          float64      `json:"accuracy"`
+	AverageAnswerTime int64        `json:"averageAnswerTime"`
+	CurrentStreak     int64        `json:"currentStreak"`
+	BestStreak        int64        `json:"bestStreak"`
+	Topics            []TopicStats `json:"topics"`
+}
+
+//QuestionResult answer of a player to a question of a past game
+type QuestionResult struct {
+	Number       int          `json:"number"`
+	QuestionText string       `json:"questionText"`
+	Answer       string       `json:"answer"`
+	PlayerAnswer string       `json:"playerAnswer"`
+	Correct      bool         `json:"correct"`
+	Score        int          `json:"score"`
+	AnswerTime   int64        `json:"answerTime"`
+	Lifeline     string       `json:"lifeline,omitempty"`
+	Explanation  *Explanation `json:"explanation,omitempty"`
+}
+
+//OpponentResult opponent of a past game with the public profile only
+type OpponentResult struct {
+	UserID      string `json:"userID"`
+	DisplayName string `json:"displayName"`
+	Avatar      string `json:"avatar"`
+	Score       int    `json:"score"`
+}
+
+//MatchHistoryEntry past game of a player
+type MatchHistoryEntry struct {
+	GameID           string           `json:"gameID"`
+	Topic            Topic            `json:"topic,string"`
+	Language         Language         `json:"language,string"`
+	CreatedTimestamp int64            `json:"createdTimestamp"`
+	Result           string           `json:"result"`
+	Score            int              `json:"score"`
+	Opponents        []OpponentResult `json:"opponents"`
+	Questions        []QuestionResult `json:"questions"`
+}
+
+func playerStatsKey(userID string) string {
+	return "stats-" + userID
+}
+
+func matchHistoryKey(userID string) string {
+	return "history-" + userID
+}
+
+func topicStatsField(topic Topic, field string) string {
+	return "topic:" + strconv.Itoa(int(topic)) + ":" + field
+}
+
+//RecordPlayerStats updates the stats and match history of the players of
+//the finished game
+func RecordPlayerStats(game *Game) {
+	for _, result := range game.Results {
+		key := playerStatsKey(result.PlayerID)
+		outcome := gameOutcome(game, result.PlayerID)
+		var answerTime, timedAnswers int64
+		for _, question := range game.Questions {
+			if taken, ok := question.AnswerTimes[result.PlayerID]; ok {
+				answerTime += taken
+				timedAnswers++
+			}
+		}
+		pipe := database.RedisClient.Pipeline()
+		pipe.HIncrBy(key, "games", 1)
+		pipe.HIncrBy(key, outcome, 1)
+		pipe.HIncrBy(key, "answered", int64(result.Answered))
+		pipe.HIncrBy(key, "correct", int64(result.Correct))
+		pipe.HIncrBy(key, "answer_time", answerTime)
+		pipe.HIncrBy(key, "timed_answers", timedAnswers)
+		pipe.HIncrBy(key, topicStatsField(game.Topic, "games"), 1)
+		pipe.HIncrBy(key, topicStatsField(game.Topic, "answered"), int64(result.Answered))
+		pipe.HIncrBy(key, topicStatsField(game.Topic, "correct"), int64(result.Correct))
+		if outcome == resultWon {
+			pipe.HIncrBy(key, topicStatsField(game.Topic, "won"), 1)
+		}
+		pipe.LPush(matchHistoryKey(result.PlayerID), game.ID)
+		pipe.LTrim(matchHistoryKey(result.PlayerID), 0, MatchHistoryLimit-1)
+		if _, err := pipe.Exec(); err != nil {
+			continue
+		}
+		if outcome != resultWon {
+			database.RedisClient.HSet(key, "current_streak", 0)
+			continue
+		}
+		streak, err := database.RedisClient.HIncrBy(key, "current_streak", 1).Result()
+		if err != nil {
+			continue
+		}
+		best, _ := database.RedisClient.HGet(key, "best_streak").Int64()
+		if streak > best {
+			database.RedisClient.HSet(key, "best_streak", streak)
+		}
+	}
+}
+
+func gameOutcome(game *Game, playerID string) string {
+	if game.Winner == playerID {
+		return resultWon
+	} else if game.Winner == "" {
+		return resultDraw
+	}
+	return resultLost
+}
+
+//GetPlayerStats returns the stats of the player
+func GetPlayerStats(userID string) (*PlayerStats, error) {
+	values, err := database.RedisClient.HGetAll(playerStatsKey(userID)).Result()
+	if err != nil {
+		return nil, err
+	}
+	field := func(name string) int64 {
+		value, _ := strconv.ParseInt(values[name], 10, 64)
+		return value
+	}
+	stats := &PlayerStats{
+		UserID:        userID,
+		Games:         field("games"),
+		Won:           field(resultWon),
+		Lost:          field(resultLost),
+		Drawn:         field(resultDraw),
+		Answered:      field("answered"),
+		Correct:       field("correct"),
+		CurrentStreak: field("current_streak"),
+		BestStreak:    field("best_streak"),
+		Topics:        make([]TopicStats, 0),
+	}
+	stats.Accuracy = accuracy(stats.Correct, stats.Answered)
+	if timedAnswers := field("timed_answers"); timedAnswers > 0 {
+		stats.AverageAnswerTime = field("answer_time") / timedAnswers
+	}
+	for _, topic := range GetTopics(false) {
+		topicStats := TopicStats{
+			Topic:    topic.ID,
+			Games:    field(topicStatsField(topic.ID, "games")),
+			Won:      field(topicStatsField(topic.ID, "won")),
+			Answered: field(topicStatsField(topic.ID, "answered")),
+			Correct:  field(topicStatsField(topic.ID, "correct")),
+		}
+		if topicStats.Games == 0 {
+			continue
+		}
+		topicStats.Accuracy = accuracy(topicStats.Correct, topicStats.Answered)
+		stats.Topics = append(stats.Topics, topicStats)
+	}
+	return stats, nil
+}
+
+func accuracy(correct int64, answered int64) float64 {
+	if answered == 0 {
+		return 0
+	}
+	return float64(correct) / float64(answered)
+}
+
+//GetMatchHistory returns a page of the past games of the player, newest first
+func GetMatchHistory(userID string, from int, size int) ([]MatchHistoryEntry, int64, error) {
+	total, err := database.RedisClient.LLen(matchHistoryKey(userID)).Result()
+	if err != nil {
+		return nil, 0, err
+	}
+	gameIDs, err := database.RedisClient.LRange(matchHistoryKey(userID), int64(from), int64(from+size-1)).Result()
+	if err != nil {
+		return nil, 0, err
+	}
+	language := English
+	if profile, err := GetProfile(userID); err == nil {
+		language = profile.PreferredLanguage
+	}
+	entries := make([]MatchHistoryEntry, 0, len(gameIDs))
+	for _, gameID := range gameIDs {
+		game, err := GetGame(gameID)
+		if err != nil {
+			continue
+		}
+		entries = append(entries, matchHistoryEntry(game, userID, language))
+	}
+	return entries, total, nil
+}
+
+//matchHistoryEntry past game of the player with the explanations in the
+//language of the player
+func matchHistoryEntry(game *Game, userID string, language Language) MatchHistoryEntry {
+	entry := MatchHistoryEntry{
+		GameID:           game.ID,
+		Topic:            game.Topic,
+		Language:         game.Language,
+		CreatedTimestamp: game.CreatedTimestamp,
+		Result:           gameOutcome(game, userID),
+		Opponents:        make([]OpponentResult, 0),
+		Questions:        make([]QuestionResult, 0, game.MaxQuestions),
+	}
+	for _, result := range game.Results {
+		if result.PlayerID == userID {
+			entry.Score = result.Score
+			continue
+		}
+		player := game.Players[result.PlayerID]
+		entry.Opponents = append(entry.Opponents, OpponentResult{
+			UserID:      result.PlayerID,
+			DisplayName: player.Name,
+			Avatar:      player.Avatar,
+			Score:       result.Score,
+		})
+	}
+	scores := game.Scores[userID]
+	for i, question := range game.Questions {
+		if i == 0 || i > game.QuestionNumber {
+			continue
+		}
+		answer := question.PlayerAnswers[userID]
+		questionResult := QuestionResult{
+			Number:       i,
+			QuestionText: question.QuestionText,
+			Answer:       question.Answer,
+			PlayerAnswer: answer,
+			Correct:      question.CorrectPlayers()[userID] && !game.Skipped(userID, i),
+			AnswerTime:   question.AnswerTimes[userID],
+			Lifeline:     usedLifeline(game, userID, i),
+			Explanation:  question.ExplanationFor(language),
+		}
+		if i < len(scores) {
+			questionResult.Score = scores[i]
+		}
+		entry.Questions = append(entry.Questions, questionResult)
+	}
+	return entry
+}
+
+//GetMyStats returns the stats of the logged in player
+func GetMyStats(c *gin.Context) {
+	sendPlayerStats(c, CurrentUserID(c))
+}
+
+//GetPlayerStatsHandler returns the stats of another player
+func GetPlayerStatsHandler(c *gin.Context) {
+	sendPlayerStats(c, c.Param("id"))
+}
+
+func sendPlayerStats(c *gin.Context, userID string) {
+	stats, err := GetPlayerStats(userID)
+	if err != nil {
+		sendError(c, "error while getting the stats")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"stats": stats,
+	})
+}
+
+//GetMyMatchHistory returns a page of the match history of the logged in player
+func GetMyMatchHistory(c *gin.Context) {
+	page, size := pageParams(c, DefaultHistoryPageSize, MaxHistoryPageSize)
+	entries, total, err := GetMatchHistory(CurrentUserID(c), (page-1)*size, size)
+	if err != nil {
+		sendError(c, "error while getting the match history")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"games": entries,
+		"total": total,
+		"page":  page,
+		"size":  size,
+	})
+}
+
+//pageParams reads the 1 based page and the page size of the request
+func pageParams(c *gin.Context, defaultSize int, maxSize int) (int, int) {
+	page, err := strconv.Atoi(strings.TrimSpace(c.Query("page")))
+	if err != nil || page < 1 {
+		page = 1
+	}
+	size, err := strconv.Atoi(strings.TrimSpace(c.Query("size")))
+	if err != nil || size < 1 {
+		size = defaultSize
+	}
+	if size > maxSize {
+		size = maxSize
+	}
+	return page, size
+}
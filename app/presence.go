@@ -0,0 +1,59 @@
+package app
+
+import (
+	"sharequiz/app/database"
+	"time"
+)
+
+//Presence of a player
+const (
+	Offline = "offline"
+	Online  = "online"
+	InGame  = "in_game"
+)
+
+//PresenceTTL presence expires unless refreshed by the client within it
+const PresenceTTL = 2 * time.Minute
+
+func presenceKey(userID string) string {
+	return "presence-" + userID
+}
+
+//SetPresence stores the presence of the player
+func SetPresence(userID string, presence string) {
+	if presence == Offline {
+		database.RedisClient.Del(presenceKey(userID))
+		return
+	}
+	database.RedisClient.Set(presenceKey(userID), presence, PresenceTTL)
+}
+
+//RefreshPresence keeps the player online unless they are in a game
+func RefreshPresence(userID string) {
+	if !database.RedisClient.SetNX(presenceKey(userID), Online, PresenceTTL).Val() {
+		database.RedisClient.Expire(presenceKey(userID), PresenceTTL)
+	}
+}
+
+//GetPresences returns the presence of each player
+func GetPresences(userIDs []string) map[string]string {
+	presences := make(map[string]string, len(userIDs))
+	if len(userIDs) == 0 {
+		return presences
+	}
+	keys := make([]string, len(userIDs))
+	for i, userID := range userIDs {
+		keys[i] = presenceKey(userID)
+	}
+	values, err := database.RedisClient.MGet(keys...).Result()
+	for i, userID := range userIDs {
+		presences[userID] = Offline
+		if err != nil {
+			continue
+		}
+		if presence, ok := values[i].(string); ok {
+			presences[userID] = presence
+		}
+	}
+	return presences
+}
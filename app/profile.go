@@ -0,0 +1,203 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sharequiz/app/database"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis"
+)
+
+const (
+	//MaxFavouriteTopics favourite topics a player can pick
+	MaxFavouriteTopics = 5
+	//MaxDisplayNameLength longest display name in characters
+	MaxDisplayNameLength = 20
+)
+
+//Profile player profile keyed by the opaque user id
+type Profile struct {
+	UserID            string   `json:"userID"`
+	DisplayName       string   `json:"displayName"`
+	Avatar            string   `json:"avatar"`
+	PreferredLanguage Language `json:"preferredLanguage,string"`
+	FavouriteTopics   []Topic  `json:"favouriteTopics"`
+	CreatedTimestamp  int64    `json:"createdTimestamp"`
+}
+
+//PublicProfile part of the profile shown to other players
+type PublicProfile struct {
+	UserID      string `json:"userID"`
+	DisplayName string `json:"displayName"`
+	Avatar      string `json:"avatar"`
+}
+
+func profileKey(userID string) string {
+	return "profile-" + userID
+}
+
+//GetProfile returns the profile of the user, users without a saved profile
+//get a default profile and unknown users ErrUnknownPlayer
+func GetProfile(userID string) (*Profile, error) {
+	data, err := database.RedisClient.Get(profileKey(userID)).Result()
+	if err == redis.Nil {
+		if !UserExists(userID) {
+			return nil, ErrUnknownPlayer
+		}
+		return &Profile{
+			UserID:            userID,
+			DisplayName:       fmt.Sprintf("Player %.4s", userID),
+			PreferredLanguage: English,
+			FavouriteTopics:   make([]Topic, 0),
+			CreatedTimestamp:  time.Now().Unix(),
+		}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	profile := &Profile{}
+	if err := json.Unmarshal([]byte(data), profile); err != nil {
+		return nil, err
+	}
+	return profile, nil
+}
+
+//GetPublicProfile returns the public part of the profile of the user
+func GetPublicProfile(userID string) (PublicProfile, error) {
+	profile, err := GetProfile(userID)
+	if err != nil {
+		return PublicProfile{}, err
+	}
+	return profile.Public(), nil
+}
+
+//GetPublicProfiles returns the public profiles of the users with a single
+//MGET, users without a saved profile get the default display name
+func GetPublicProfiles(userIDs []string) (map[string]PublicProfile, error) {
+	profiles := make(map[string]PublicProfile, len(userIDs))
+	if len(userIDs) == 0 {
+		return profiles, nil
+	}
+	keys := make([]string, len(userIDs))
+	for i, userID := range userIDs {
+		keys[i] = profileKey(userID)
+	}
+	values, err := database.RedisClient.MGet(keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+	for i, userID := range userIDs {
+		profile := &Profile{UserID: userID, DisplayName: fmt.Sprintf("Player %.4s", userID)}
+		if data, ok := values[i].(string); ok {
+			if err := json.Unmarshal([]byte(data), profile); err != nil {
+				return nil, err
+			}
+		}
+		profiles[userID] = profile.Public()
+	}
+	return profiles, nil
+}
+
+//Public part of the profile
+func (p *Profile) Public() PublicProfile {
+	return PublicProfile{UserID: p.UserID, DisplayName: p.DisplayName, Avatar: p.Avatar}
+}
+
+//SaveProfile validates and stores the profile
+func SaveProfile(profile *Profile) error {
+	profile.DisplayName = strings.TrimSpace(profile.DisplayName)
+	length := utf8.RuneCountInString(profile.DisplayName)
+	if length < 2 || length > MaxDisplayNameLength {
+		return errors.New("display name should have 2 to 20 characters")
+	}
+	if profile.Avatar != "" {
+		avatarURL, err := url.Parse(profile.Avatar)
+		if err != nil || (avatarURL.Scheme != "https" && !strings.HasPrefix(profile.Avatar, "/static/")) {
+			return errors.New("avatar should be an https or static url")
+		}
+	}
+	if !LanguageEnabled(profile.PreferredLanguage) {
+		return errors.New("unknown language")
+	}
+	if len(profile.FavouriteTopics) > MaxFavouriteTopics {
+		return errors.New("at most 5 favourite topics")
+	}
+	for _, topic := range profile.FavouriteTopics {
+		if !TopicEnabled(topic) {
+			return errors.New("unknown topic")
+		}
+	}
+	profileJSON, err := json.Marshal(profile)
+	if err != nil {
+		return err
+	}
+	return database.RedisClient.Set(profileKey(profile.UserID), string(profileJSON), 0).Err()
+}
+
+//GetMyProfile returns the profile of the logged in player
+func GetMyProfile(c *gin.Context) {
+	profile, err := GetProfile(CurrentUserID(c))
+	if err != nil {
+		sendError(c, "error while getting the profile")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"profile": profile,
+	})
+}
+
+//UpdateMyProfile updates the display name, avatar, preferred language and
+//favourite topics of the logged in player
+func UpdateMyProfile(c *gin.Context) {
+	update := Profile{}
+	if err := c.ShouldBindJSON(&update); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "check the profile",
+		})
+		return
+	}
+	profile, err := GetProfile(CurrentUserID(c))
+	if err != nil {
+		sendError(c, "error while updating the profile")
+		return
+	}
+	profile.DisplayName = update.DisplayName
+	profile.Avatar = update.Avatar
+	profile.PreferredLanguage = update.PreferredLanguage
+	profile.FavouriteTopics = update.FavouriteTopics
+	if profile.FavouriteTopics == nil {
+		profile.FavouriteTopics = make([]Topic, 0)
+	}
+	if err := SaveProfile(profile); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"profile": profile,
+	})
+}
+
+//GetPlayerProfile returns the public profile of another player
+func GetPlayerProfile(c *gin.Context) {
+	profile, err := GetPublicProfile(c.Param("id"))
+	if err == ErrUnknownPlayer {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": err.Error(),
+		})
+		return
+	} else if err != nil {
+		sendError(c, "error while getting the profile")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"profile": profile,
+	})
+}
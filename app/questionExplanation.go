@@ -0,0 +1,69 @@
+package app
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+)
+
+//MaxExplanationLength longest explanation in characters
+const MaxExplanationLength = 1000
+
+//Explanation why the answer is right with a link to the source
+type Explanation struct {
+	Text      string `json:"text" yaml:"text"`
+	SourceURL string `json:"sourceURL,omitempty" yaml:"source_url,omitempty"`
+}
+
+//ExplanationFor returns the explanation in the language, falling back to the
+//language of the question and then English
+func (q Question) ExplanationFor(language Language) *Explanation {
+	for _, candidate := range []Language{language, q.Language, English} {
+		if explanation, ok := q.Explanations[candidate.Key()]; ok {
+			return &explanation
+		}
+	}
+	return nil
+}
+
+//validateExplanations checks the explanations are keyed by known languages
+//and link to web sources
+func validateExplanations(explanations map[string]Explanation) error {
+	for name, explanation := range explanations {
+		if _, ok := ParseLanguage(name); !ok {
+			return errors.New("unknown explanation language " + name)
+		}
+		if strings.TrimSpace(explanation.Text) == "" {
+			return errors.New("explanation text is required")
+		}
+		if utf8.RuneCountInString(explanation.Text) > MaxExplanationLength {
+			return errors.New("explanation should have at most 1000 characters")
+		}
+		if explanation.SourceURL == "" {
+			continue
+		}
+		sourceURL, err := url.Parse(explanation.SourceURL)
+		if err != nil || (sourceURL.Scheme != "https" && sourceURL.Scheme != "http") {
+			return errors.New("source should be a web link")
+		}
+	}
+	return nil
+}
+
+//normalizeExplanations keys the explanations by the language key
+//used in the stored documents
+func normalizeExplanations(explanations map[string]Explanation) map[string]Explanation {
+	if len(explanations) == 0 {
+		return nil
+	}
+	normalized := make(map[string]Explanation, len(explanations))
+	for name, explanation := range explanations {
+		if language, ok := ParseLanguage(name); ok {
+			normalized[language.Key()] = explanation
+		} else {
+			normalized[name] = explanation
+		}
+	}
+	return normalized
+}
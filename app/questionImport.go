@@ -0,0 +1,339 @@
+package app
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	//FormatCSV questions as csv with a header row
+	FormatCSV = "csv"
+	//FormatJSONL questions as one json document per line
+	FormatJSONL = "jsonl"
+	//importBatchSize questions stored per bulk request
+	importBatchSize = 500
+)
+
+//csvHeader columns of the question csv files, topics are separated by |
+var csvHeader = []string{"question_text", "option_1", "option_2", "option_3", "option_4",
+	"option_5", "option_6", "answer", "topics", "language", "difficulty", "type", "image_url", "tolerance",
+	"explanation", "source_url"}
+
+//ImportRowError problem with a row of an imported file
+type ImportRowError struct {
+	Row     int    `json:"row"`
+	Message string `json:"message"`
+}
+
+//ImportReport result of an import, on a dry run Imported counts the questions
+//which would have been imported
+type ImportReport struct {
+	DryRun     bool             `json:"dryRun"`
+	Rows       int              `json:"rows"`
+	Imported   int              `json:"imported"`
+	Duplicates int              `json:"duplicates"`
+	Errors     []ImportRowError `json:"errors"`
+}
+
+type importRow struct {
+	row      int
+	question Question
+}
+
+//FormatFromFileName returns the question file format of the file name
+func FormatFromFileName(name string) string {
+	if strings.ToLower(filepath.Ext(name)) == ".csv" {
+		return FormatCSV
+	}
+	return FormatJSONL
+}
+
+//ImportQuestions validates the questions read in the format and stores the
+//ones which are not duplicates of each other or of the stored questions
+func ImportQuestions(reader io.Reader, format string, dryRun bool) (*ImportReport, error) {
+	report := &ImportReport{DryRun: dryRun, Errors: make([]ImportRowError, 0)}
+	var rows []importRow
+	var err error
+	switch format {
+	case FormatCSV:
+		rows, err = readCSVQuestions(reader, report)
+	case FormatJSONL:
+		rows, err = readJSONLQuestions(reader, report)
+	default:
+		return nil, errors.New("unknown format " + format)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	texts := make([]string, len(rows))
+	for i, row := range rows {
+		texts[i] = NormalizeQuestionText(row.question.QuestionText)
+	}
+	existing, err := QuestionRepo.ExistingQuestionTexts(texts)
+	if err != nil {
+		return nil, err
+	}
+	firstRow := make(map[string]int)
+	unique := make([]importRow, 0, len(rows))
+	for i, row := range rows {
+		if existing[texts[i]] {
+			report.Duplicates++
+			report.addError(row.row, "duplicate of an existing question")
+		} else if first, ok := firstRow[texts[i]]; ok {
+			report.Duplicates++
+			report.addError(row.row, "duplicate of row "+strconv.Itoa(first))
+		} else {
+			firstRow[texts[i]] = row.row
+			unique = append(unique, row)
+		}
+	}
+
+	if dryRun {
+		report.Imported = len(unique)
+		report.sortErrors()
+		return report, nil
+	}
+	for start := 0; start < len(unique); start += importBatchSize {
+		end := start + importBatchSize
+		if end > len(unique) {
+			end = len(unique)
+		}
+		batch := unique[start:end]
+		questions := make([]Question, len(batch))
+		for i, row := range batch {
+			questions[i] = row.question
+		}
+		itemErrors, err := QuestionRepo.BulkCreateQuestions(questions)
+		if err != nil {
+			return nil, err
+		}
+		for i, itemError := range itemErrors {
+			if itemError != nil {
+				report.addError(batch[i].row, itemError.Error())
+			} else {
+				report.Imported++
+			}
+		}
+	}
+	report.sortErrors()
+	return report, nil
+}
+
+//ExportQuestions writes the questions matching the filter in the format
+func ExportQuestions(writer io.Writer, format string, filter QuestionFilter) error {
+	switch format {
+	case FormatCSV:
+		csvWriter := csv.NewWriter(writer)
+		if err := csvWriter.Write(csvHeader); err != nil {
+			return err
+		}
+		err := QuestionRepo.ExportQuestions(filter, func(question Question) error {
+			return csvWriter.Write(questionToCSV(question))
+		})
+		if err != nil {
+			return err
+		}
+		csvWriter.Flush()
+		return csvWriter.Error()
+	case FormatJSONL:
+		encoder := json.NewEncoder(writer)
+		return QuestionRepo.ExportQuestions(filter, func(question Question) error {
+			document := newQuestionDocument(question)
+			document.NormalizedText = ""
+			return encoder.Encode(document)
+		})
+	}
+	return errors.New("unknown format " + format)
+}
+
+func (r *ImportReport) addError(row int, message string) {
+	r.Errors = append(r.Errors, ImportRowError{Row: row, Message: message})
+}
+
+func (r *ImportReport) sortErrors() {
+	sort.SliceStable(r.Errors, func(i, j int) bool {
+		return r.Errors[i].Row < r.Errors[j].Row
+	})
+}
+
+func readCSVQuestions(reader io.Reader, report *ImportReport) ([]importRow, error) {
+	csvReader := csv.NewReader(reader)
+	csvReader.FieldsPerRecord = -1
+	header, err := csvReader.Read()
+	if err != nil {
+		return nil, errors.New("missing csv header")
+	}
+	columns := make(map[string]int)
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	if _, ok := columns["question_text"]; !ok {
+		return nil, errors.New("csv header needs a question_text column")
+	}
+	column := func(record []string, name string) string {
+		if i, ok := columns[name]; ok && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+
+	rows := make([]importRow, 0)
+	for row := 2; ; row++ {
+		record, err := csvReader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		report.Rows++
+		document := questionDocument{
+			Type:         QuestionType(strings.ToLower(column(record, "type"))),
+			QuestionText: column(record, "question_text"),
+			ImageURL:     column(record, "image_url"),
+			Answer:       column(record, "answer"),
+			Language:     column(record, "language"),
+			Options:      make([]string, 0, MaxOptions),
+		}
+		if document.Type == "text" {
+			document.Type = TextQuestion
+		}
+		if tolerance := column(record, "tolerance"); tolerance != "" {
+			value, err := strconv.ParseFloat(tolerance, 64)
+			if err != nil {
+				report.addError(row, "tolerance must be a number")
+				continue
+			}
+			document.Tolerance = value
+		}
+		for i := 1; i <= MaxOptions; i++ {
+			if option := column(record, "option_"+strconv.Itoa(i)); option != "" {
+				document.Options = append(document.Options, option)
+			}
+		}
+		for _, topic := range strings.Split(column(record, "topics"), "|") {
+			if topic = strings.TrimSpace(topic); topic != "" {
+				document.Topics = append(document.Topics, topic)
+			}
+		}
+		if explanation := column(record, "explanation"); explanation != "" {
+			document.Explanations = map[string]Explanation{
+				strings.ToLower(document.Language): {Text: explanation, SourceURL: column(record, "source_url")},
+			}
+		}
+		difficulty, ok := parseDifficulty(column(record, "difficulty"))
+		if !ok {
+			report.addError(row, "unknown difficulty")
+			continue
+		}
+		document.Difficulty = difficulty
+		if question, err := importQuestion(document); err != nil {
+			report.addError(row, err.Error())
+		} else {
+			rows = append(rows, importRow{row: row, question: question})
+		}
+	}
+	return rows, nil
+}
+
+func readJSONLQuestions(reader io.Reader, report *ImportReport) ([]importRow, error) {
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	rows := make([]importRow, 0)
+	for row := 1; scanner.Scan(); row++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		report.Rows++
+		document := questionDocument{}
+		if err := json.Unmarshal([]byte(line), &document); err != nil {
+			report.addError(row, "invalid json")
+			continue
+		}
+		if question, err := importQuestion(document); err != nil {
+			report.addError(row, err.Error())
+		} else {
+			rows = append(rows, importRow{row: row, question: question})
+		}
+	}
+	return rows, scanner.Err()
+}
+
+//importQuestion converts and validates an imported document, unlike stored
+//documents unknown topics and languages are rejected
+func importQuestion(document questionDocument) (Question, error) {
+	question := Question{
+		Type:         document.Type,
+		QuestionText: document.QuestionText,
+		ImageURL:     document.ImageURL,
+		Options:      document.Options,
+		Answer:       document.Answer,
+		Tolerance:    document.Tolerance,
+		Explanations: normalizeExplanations(document.Explanations),
+		Difficulty:   document.Difficulty,
+	}
+	for _, name := range document.Topics {
+		topic, ok := ParseTopic(name)
+		if !ok {
+			return Question{}, errors.New("unknown topic " + name)
+		}
+		question.Topics = append(question.Topics, topic)
+	}
+	language, ok := ParseLanguage(document.Language)
+	if !ok {
+		return Question{}, errors.New("unknown language " + document.Language)
+	}
+	question.Language = language
+	return question, ValidateQuestion(question)
+}
+
+func questionToCSV(question Question) []string {
+	record := make([]string, len(csvHeader))
+	record[0] = question.QuestionText
+	for i, option := range question.Options {
+		if i < MaxOptions {
+			record[1+i] = option
+		}
+	}
+	topics := make([]string, len(question.Topics))
+	for i, topic := range question.Topics {
+		topics[i] = topic.Key()
+	}
+	record[7] = question.Answer
+	record[8] = strings.Join(topics, "|")
+	record[9] = question.Language.Key()
+	if question.Difficulty != 0 {
+		record[10] = strings.ToLower(question.Difficulty.String())
+	}
+	record[11] = string(question.Type)
+	record[12] = question.ImageURL
+	if question.Tolerance != 0 {
+		record[13] = strconv.FormatFloat(question.Tolerance, 'f', -1, 64)
+	}
+	if explanation := question.ExplanationFor(question.Language); explanation != nil {
+		record[14] = explanation.Text
+		record[15] = explanation.SourceURL
+	}
+	return record
+}
+
+func parseDifficulty(name string) (Difficulty, bool) {
+	if name == "" {
+		return 0, true
+	}
+	for _, difficulty := range []Difficulty{Easy, Medium, Hard} {
+		if strings.EqualFold(difficulty.String(), name) || strconv.Itoa(int(difficulty)) == name {
+			return difficulty, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,281 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"sharequiz/app/database"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis"
+)
+
+const (
+	//ReportOpen report waiting for an admin
+	ReportOpen = "open"
+	//ReportDismissed report closed without changing the question
+	ReportDismissed = "dismissed"
+	//ReportResolved report closed after retiring or fixing the question
+	ReportResolved = "resolved"
+	//ReportsForReview open reports after which a live question is taken out of the games
+	ReportsForReview = 3
+
+	lastReportIDKey = "last_report_id_key"
+	reportsKey      = "question-reports"
+	openReportsKey  = "question-reports-open"
+)
+
+//ReportReasons reasons a player can give when reporting a question
+var ReportReasons = []string{"wrong_answer", "offensive", "typo", "other"}
+
+//ErrReportNotFound returned when no report exists for the id
+var ErrReportNotFound = errors.New("report not found")
+
+//ErrAlreadyReported returned when the player has an open report of the question
+var ErrAlreadyReported = errors.New("question already reported")
+
+//ReportData sent by a player to report a question of a game
+type ReportData struct {
+	GameID         string `json:"gameID"`
+	QuestionNumber int    `json:"questionNumber"`
+	Reason         string `json:"reason"`
+	Comment        string `json:"comment"`
+}
+
+//QuestionReport report of a question by a player
+type QuestionReport struct {
+	ID               string `json:"id"`
+	QuestionID       string `json:"questionID"`
+	GameID           string `json:"gameID"`
+	PlayerID         string `json:"playerID"`
+	Reason           string `json:"reason"`
+	Comment          string `json:"comment"`
+	Status           string `json:"status"`
+	Resolution       string `json:"resolution,omitempty"`
+	CreatedTimestamp int64  `json:"createdTimestamp"`
+}
+
+func openReportsForQuestionKey(questionID string) string {
+	return "question-reports-open-" + questionID
+}
+
+//openReportersForQuestionKey players with an open report of the question
+func openReportersForQuestionKey(questionID string) string {
+	return "question-reporters-open-" + questionID
+}
+
+//ReportQuestion report a question during or after a game by the logged in player.
+func ReportQuestion(c *gin.Context) {
+	data := ReportData{}
+	if err := c.ShouldBindJSON(&data); err != nil {
+		sendError(c, "check the report")
+		return
+	}
+	report, err := SaveQuestionReport(CurrentUserID(c), data)
+	if err != nil {
+		sendError(c, err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"reportID": report.ID,
+	})
+}
+
+//SaveQuestionReport stores the report of a question the player was asked in
+//the game. Questions reported by ReportsForReview different players go to
+//review, a player can only have one open report of a question.
+func SaveQuestionReport(playerID string, data ReportData) (*QuestionReport, error) {
+	if !isReportReason(data.Reason) {
+		return nil, errors.New("unknown reason")
+	}
+	gameData, err := database.RedisClient.Get(data.GameID).Result()
+	if err != nil {
+		return nil, errors.New("game not found")
+	}
+	game := &Game{}
+	if err := json.Unmarshal([]byte(gameData), game); err != nil {
+		return nil, err
+	}
+	if _, ok := game.Players[playerID]; !ok {
+		return nil, errors.New("player not in the game")
+	}
+	if data.QuestionNumber < 1 || data.QuestionNumber > game.QuestionNumber ||
+		data.QuestionNumber >= len(game.Questions) {
+		return nil, errors.New("question not asked in the game")
+	}
+	questionID := game.Questions[data.QuestionNumber].ID
+
+	added, err := database.RedisClient.SAdd(openReportersForQuestionKey(questionID), playerID).Result()
+	if err != nil {
+		return nil, err
+	} else if added == 0 {
+		return nil, ErrAlreadyReported
+	}
+	reportID, err := database.RedisClient.Incr(lastReportIDKey).Result()
+	if err != nil {
+		database.RedisClient.SRem(openReportersForQuestionKey(questionID), playerID)
+		return nil, err
+	}
+	report := &QuestionReport{
+		ID:               strconv.FormatInt(reportID, 10),
+		QuestionID:       questionID,
+		GameID:           data.GameID,
+		PlayerID:         playerID,
+		Reason:           data.Reason,
+		Comment:          data.Comment,
+		Status:           ReportOpen,
+		CreatedTimestamp: time.Now().Unix(),
+	}
+	if err := saveReport(report); err != nil {
+		database.RedisClient.SRem(openReportersForQuestionKey(questionID), playerID)
+		return nil, err
+	}
+	database.RedisClient.ZAdd(openReportsKey, redis.Z{Score: float64(report.CreatedTimestamp), Member: report.ID})
+	database.RedisClient.SAdd(openReportsForQuestionKey(questionID), report.ID)
+	reporters, err := database.RedisClient.SCard(openReportersForQuestionKey(questionID)).Result()
+	if err == nil && reporters >= ReportsForReview {
+		question, err := QuestionRepo.GetQuestion(questionID)
+		if err == nil && question.State == Live {
+			log.Println("question " + questionID + " sent for review after reports")
+			QuestionRepo.SetState(questionID, PendingReview)
+		}
+	}
+	return report, nil
+}
+
+//GetReports returns the open reports, oldest first, or all the reports
+func GetReports(onlyOpen bool) ([]QuestionReport, error) {
+	reports := make([]QuestionReport, 0)
+	var values []string
+	if onlyOpen {
+		ids, err := database.RedisClient.ZRange(openReportsKey, 0, -1).Result()
+		if err != nil || len(ids) == 0 {
+			return reports, err
+		}
+		found, err := database.RedisClient.HMGet(reportsKey, ids...).Result()
+		if err != nil {
+			return nil, err
+		}
+		for _, value := range found {
+			if str, ok := value.(string); ok {
+				values = append(values, str)
+			}
+		}
+	} else {
+		all, err := database.RedisClient.HVals(reportsKey).Result()
+		if err != nil {
+			return nil, err
+		}
+		values = all
+	}
+	for _, value := range values {
+		report := QuestionReport{}
+		if err := json.Unmarshal([]byte(value), &report); err == nil {
+			reports = append(reports, report)
+		}
+	}
+	return reports, nil
+}
+
+//GetReport returns the report with the id
+func GetReport(id string) (*QuestionReport, error) {
+	value, err := database.RedisClient.HGet(reportsKey, id).Result()
+	if err == redis.Nil {
+		return nil, ErrReportNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	report := &QuestionReport{}
+	if err := json.Unmarshal([]byte(value), report); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+//DismissReport closes the report without changing the question
+func DismissReport(id string, resolution string) error {
+	report, err := GetReport(id)
+	if err != nil {
+		return err
+	}
+	return closeReport(report, ReportDismissed, resolution)
+}
+
+//RetireReportedQuestion retires the question of the report and closes all its open reports
+func RetireReportedQuestion(id string, resolution string) error {
+	report, err := GetReport(id)
+	if err != nil {
+		return err
+	}
+	if err := QuestionRepo.SetState(report.QuestionID, Retired); err != nil {
+		return err
+	}
+	return closeQuestionReports(report.QuestionID, resolution)
+}
+
+//FixReportedQuestion replaces the question of the report with the corrected
+//question, makes it live again and closes all its open reports
+func FixReportedQuestion(id string, question Question, resolution string) error {
+	report, err := GetReport(id)
+	if err != nil {
+		return err
+	}
+	question.ID = report.QuestionID
+	question.State = Live
+	if err := ValidateQuestion(question); err != nil {
+		return err
+	}
+	if _, err := QuestionRepo.UpdateQuestion(question); err != nil {
+		return err
+	}
+	return closeQuestionReports(report.QuestionID, resolution)
+}
+
+func closeQuestionReports(questionID string, resolution string) error {
+	ids, err := database.RedisClient.SMembers(openReportsForQuestionKey(questionID)).Result()
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		report, err := GetReport(id)
+		if err != nil {
+			continue
+		}
+		if err := closeReport(report, ReportResolved, resolution); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func closeReport(report *QuestionReport, status string, resolution string) error {
+	report.Status = status
+	report.Resolution = resolution
+	if err := saveReport(report); err != nil {
+		return err
+	}
+	database.RedisClient.ZRem(openReportsKey, report.ID)
+	database.RedisClient.SRem(openReportsForQuestionKey(report.QuestionID), report.ID)
+	database.RedisClient.SRem(openReportersForQuestionKey(report.QuestionID), report.PlayerID)
+	return nil
+}
+
+func saveReport(report *QuestionReport) error {
+	reportJSON, err := json.Marshal(report)
+	if err != nil {
+		return err
+	}
+	return database.RedisClient.HSet(reportsKey, report.ID, string(reportJSON)).Err()
+}
+
+func isReportReason(reason string) bool {
+	for _, known := range ReportReasons {
+		if known == reason {
+			return true
+		}
+	}
+	return false
+}
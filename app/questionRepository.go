@@ -0,0 +1,224 @@
+package app
+
+import (
+	"errors"
+	"log"
+	"os"
+	"sharequiz/app/database"
+	"strings"
+	"unicode"
+)
+
+//ErrQuestionNotFound returned when no question exists for the id
+var ErrQuestionNotFound = errors.New("question not found")
+
+//QuestionFilter selects the questions for a game, zero values match everything
+type QuestionFilter struct {
+	Topic      Topic
+	Language   Language
+	Difficulty Difficulty
+	State      QuestionState
+	ExcludeIDs []string
+	//Seed makes the random selection reproducible while the pool does not
+	//change, 0 selects at random
+	Seed int64
+}
+
+//QuestionSearch admin search over all the questions, an empty text matches everything
+type QuestionSearch struct {
+	QuestionFilter
+	Text string
+	From int
+	Size int
+}
+
+//QuestionRepository storage of the quiz questions
+type QuestionRepository interface {
+	//RandomQuestions returns up to count random questions matching the filter
+	RandomQuestions(filter QuestionFilter, count int) ([]Question, error)
+	//GetQuestion returns the question with the id
+	GetQuestion(id string) (Question, error)
+	//SetDifficulty updates the difficulty of the question
+	SetDifficulty(id string, difficulty Difficulty) error
+	//SetState updates the moderation state of the question
+	SetState(id string, state QuestionState) error
+	//SearchQuestions returns a page of the matching questions and the total matches
+	SearchQuestions(search QuestionSearch) ([]Question, int, error)
+	//CreateQuestion stores a new question and returns it with its id
+	CreateQuestion(question Question) (Question, error)
+	//UpdateQuestion replaces the stored question with the same id, including
+	//clearing the fields missing in the question, and returns the stored question
+	UpdateQuestion(question Question) (Question, error)
+	//DeleteQuestion deletes the question with the id
+	DeleteQuestion(id string) error
+	//BulkCreateQuestions stores the questions returning the error of each question in order
+	BulkCreateQuestions(questions []Question) ([]error, error)
+	//ExistingQuestionTexts returns which of the normalized question texts are already stored
+	ExistingQuestionTexts(normalizedTexts []string) (map[string]bool, error)
+	//ExportQuestions calls handle with every question matching the filter
+	ExportQuestions(filter QuestionFilter, handle func(Question) error) error
+}
+
+//QuestionRepo repository used for fetching the questions
+var QuestionRepo QuestionRepository
+
+//questionDocument question as stored in elastic search and question files
+type questionDocument struct {
+	Type         QuestionType `json:"type,omitempty" yaml:"type,omitempty"`
+	QuestionText string       `json:"question_text" yaml:"question_text"`
+	ImageURL     string       `json:"image_url,omitempty" yaml:"image_url,omitempty"`
+	Options      []string     `json:"options" yaml:"options"`
+	Answer       string       `json:"answer" yaml:"answer"`
+	Tolerance    float64      `json:"tolerance,omitempty" yaml:"tolerance,omitempty"`
+	//Explanations keyed by the language key
+	Explanations map[string]Explanation `json:"explanations,omitempty" yaml:"explanations,omitempty"`
+	Topics       []string               `json:"topics" yaml:"topics"`
+	Language     string                 `json:"language" yaml:"language"`
+	Difficulty   Difficulty             `json:"difficulty,omitempty" yaml:"difficulty,omitempty"`
+	//State missing for the questions stored before moderation, which are live
+	State QuestionState `json:"state,omitempty" yaml:"state,omitempty"`
+	//NormalizedText used for finding duplicate questions
+	NormalizedText string `json:"normalized_text,omitempty" yaml:"normalized_text,omitempty"`
+}
+
+//questionMapping elastic search mapping of the questions index
+var questionMapping = map[string]interface{}{
+	"properties": map[string]interface{}{
+		"type":            map[string]string{"type": "keyword"},
+		"question_text":   map[string]string{"type": "text"},
+		"image_url":       map[string]string{"type": "keyword", "index": "false"},
+		"tolerance":       map[string]string{"type": "double"},
+		"explanations":    map[string]interface{}{"type": "object", "enabled": false},
+		"options":         map[string]string{"type": "keyword"},
+		"answer":          map[string]string{"type": "keyword"},
+		"topics":          map[string]string{"type": "keyword"},
+		"language":        map[string]string{"type": "keyword"},
+		"difficulty":      map[string]string{"type": "integer"},
+		"state":           map[string]string{"type": "integer"},
+		"normalized_text": map[string]string{"type": "keyword"},
+	},
+}
+
+//InitQuestionRepository initialises the question repository. Setting
+//QUESTIONS_FILE uses the questions from a json or yaml file instead of elastic.
+func InitQuestionRepository() {
+	path := os.Getenv("QUESTIONS_FILE")
+	if path == "" {
+		database.InitElastic()
+		types, err := database.EnsureQuestionIndex(questionMapping)
+		if err != nil {
+			log.Panicln("error while updating the questions mapping", err)
+		}
+		//the duplicate check matches the whole normalized text
+		if types["normalized_text"] != "keyword" {
+			log.Panicln("normalized_text of the questions index is mapped as " + types["normalized_text"] +
+				", reindex the questions with the keyword mapping")
+		}
+		repo := NewElasticQuestionRepository()
+		if err := repo.BackfillNormalizedTexts(); err != nil {
+			log.Panicln("error while adding the normalized texts of the questions", err)
+		}
+		QuestionRepo = repo
+		return
+	}
+	repo, err := NewFileQuestionRepository(path)
+	if err != nil {
+		log.Panicln(err)
+	}
+	QuestionRepo = repo
+}
+
+func (d questionDocument) toQuestion(id string) (Question, error) {
+	if d.QuestionText == "" || d.Answer == "" || (len(d.Options) < 2 && d.Type != NumericQuestion) {
+		return Question{}, errors.New("malformed question " + id)
+	}
+	topics := make([]Topic, 0, len(d.Topics))
+	for _, name := range d.Topics {
+		if topic, ok := ParseTopic(name); ok {
+			topics = append(topics, topic)
+		}
+	}
+	language, _ := ParseLanguage(d.Language)
+	return Question{
+		ID:            id,
+		Type:          d.Type,
+		QuestionText:  d.QuestionText,
+		ImageURL:      d.ImageURL,
+		Options:       d.Options,
+		Answer:        d.Answer,
+		Tolerance:     d.Tolerance,
+		Explanations:  d.Explanations,
+		Topics:        topics,
+		Language:      language,
+		Difficulty:    d.Difficulty,
+		State:         d.state(),
+		PlayerAnswers: make(map[string]string),
+	}, nil
+}
+
+func newQuestionDocument(question Question) questionDocument {
+	topics := make([]string, len(question.Topics))
+	for i, topic := range question.Topics {
+		topics[i] = topic.Key()
+	}
+	return questionDocument{
+		Type:           question.Type,
+		QuestionText:   question.QuestionText,
+		ImageURL:       question.ImageURL,
+		Options:        question.Options,
+		Answer:         question.Answer,
+		Tolerance:      question.Tolerance,
+		Explanations:   normalizeExplanations(question.Explanations),
+		Topics:         topics,
+		Language:       question.Language.Key(),
+		Difficulty:     question.Difficulty,
+		State:          question.State,
+		NormalizedText: NormalizeQuestionText(question.QuestionText),
+	}
+}
+
+//NormalizeQuestionText lower cases the text keeping only letters, digits and
+//single spaces, so that questions differing in case or punctuation match
+func NormalizeQuestionText(text string) string {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && !unicode.IsMark(r)
+	})
+	return strings.Join(words, " ")
+}
+
+func (d questionDocument) state() QuestionState {
+	if d.State == 0 {
+		return Live
+	}
+	return d.State
+}
+
+func (d questionDocument) containsText(text string) bool {
+	return strings.Contains(strings.ToLower(d.QuestionText), strings.ToLower(text))
+}
+
+func (d questionDocument) matches(id string, filter QuestionFilter) bool {
+	for _, excluded := range filter.ExcludeIDs {
+		if excluded == id {
+			return false
+		}
+	}
+	if filter.Language != 0 && d.Language != filter.Language.Key() {
+		return false
+	}
+	if filter.Difficulty != 0 && d.Difficulty != filter.Difficulty {
+		return false
+	}
+	if filter.State != 0 && d.state() != filter.State {
+		return false
+	}
+	if filter.Topic == 0 {
+		return true
+	}
+	for _, topic := range d.Topics {
+		if topic == filter.Topic.Key() {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,154 @@
+package app
+
+import (
+	"errors"
+	"log"
+)
+
+//ErrNotEnoughQuestions returned when the question pool cannot fill a game
+var ErrNotEnoughQuestions = errors.New("not enough questions for the topic and language")
+
+//questionSelector picks questions for a single game without repeating them
+type questionSelector struct {
+	seen     []string
+	selected map[string]bool
+	seed     int64
+}
+
+//GetGameQuestions get game questions. The questions are 1 indexed like the
+//scores, so the first entry is a placeholder which is never asked. The
+//questions follow the DifficultyCurve where the pool has calibrated questions
+//and questions already seen by any of the players are avoided while the pool
+//allows it. When the topic does not have enough questions the related topics
+//of the catalog and then English questions of the topic are used, otherwise
+//ErrNotEnoughQuestions is returned.
+func GetGameQuestions(topic Topic, language Language, numOfQuestions int, playerIDs []string) ([]Question, error) {
+	seen, err := GetSeenQuestions(playerIDs)
+	if err != nil {
+		log.Println("ignoring seen questions", err)
+		seen = nil
+	}
+	return selectGameQuestions(topic, language, numOfQuestions, &questionSelector{seen: seen, selected: make(map[string]bool)})
+}
+
+//GetSeededGameQuestions selects the game questions like GetGameQuestions
+//without seen questions, the same seed gives the same questions while the
+//pool does not change
+func GetSeededGameQuestions(topic Topic, language Language, numOfQuestions int, seed int64) ([]Question, error) {
+	return selectGameQuestions(topic, language, numOfQuestions, &questionSelector{selected: make(map[string]bool), seed: seed})
+}
+
+func selectGameQuestions(topic Topic, language Language, numOfQuestions int, selector *questionSelector) ([]Question, error) {
+	curve := DifficultyCurve(numOfQuestions)
+	counts := make(map[Difficulty]int)
+	for _, difficulty := range curve {
+		counts[difficulty]++
+	}
+	byDifficulty := make(map[Difficulty][]Question)
+	var remaining []Question
+	total := 0
+	//seen questions are only allowed once every filter was tried without them,
+	//and only for the places the unseen questions cannot fill
+	for _, excluded := range [][]string{selector.seen, nil} {
+		for _, difficulty := range []Difficulty{Easy, Medium, Hard} {
+			filter := QuestionFilter{Topic: topic, Language: language, Difficulty: difficulty, State: Live}
+			count := counts[difficulty] - len(byDifficulty[difficulty])
+			if open := numOfQuestions - total - len(remaining); open < count {
+				count = open
+			}
+			picked, err := selector.pick([]QuestionFilter{filter}, excluded, count)
+			if err != nil {
+				return nil, err
+			}
+			byDifficulty[difficulty] = append(byDifficulty[difficulty], picked...)
+			total += len(picked)
+		}
+		picked, err := selector.pick(fallbackFilters(topic, language), excluded, numOfQuestions-total-len(remaining))
+		if err != nil {
+			return nil, err
+		}
+		remaining = append(remaining, picked...)
+		if total+len(remaining) == numOfQuestions || len(selector.seen) == 0 {
+			break
+		}
+	}
+	if total+len(remaining) < numOfQuestions {
+		return nil, ErrNotEnoughQuestions
+	}
+
+	questions := make([]Question, 1, numOfQuestions+1)
+	questions[0] = Question{PlayerAnswers: make(map[string]string)}
+	for _, difficulty := range curve {
+		if picked := byDifficulty[difficulty]; len(picked) > 0 {
+			questions = append(questions, picked[0])
+			byDifficulty[difficulty] = picked[1:]
+		} else {
+			questions = append(questions, remaining[0])
+			remaining = remaining[1:]
+		}
+	}
+	return questions, nil
+}
+
+//DifficultyCurve difficulty of each question of a game, going from easy to hard
+func DifficultyCurve(numOfQuestions int) []Difficulty {
+	curve := make([]Difficulty, numOfQuestions)
+	for i := range curve {
+		switch {
+		case i < numOfQuestions*3/10:
+			curve[i] = Easy
+		case i < numOfQuestions*7/10:
+			curve[i] = Medium
+		default:
+			curve[i] = Hard
+		}
+	}
+	return curve
+}
+
+//pick returns up to count questions trying the filters in order without the
+//excluded questions
+func (s *questionSelector) pick(filters []QuestionFilter, excluded []string, count int) ([]Question, error) {
+	questions := make([]Question, 0, count)
+	if count <= 0 {
+		return questions, nil
+	}
+	for i, filter := range filters {
+		filter.ExcludeIDs = excluded
+		filter.Seed = s.seed
+		result, err := QuestionRepo.RandomQuestions(filter, count)
+		if err != nil {
+			return nil, err
+		}
+		for _, question := range result {
+			if len(questions) == count {
+				break
+			}
+			if s.selected[question.ID] {
+				continue
+			}
+			s.selected[question.ID] = true
+			questions = append(questions, question)
+		}
+		if len(questions) == count {
+			return questions, nil
+		}
+		if i < len(filters)-1 {
+			log.Println("widening question search after " + filter.Topic.String() + " in " + filter.Language.String())
+		}
+	}
+	return questions, nil
+}
+
+//fallbackFilters filters of live questions to try in order when selecting the game questions
+func fallbackFilters(topic Topic, language Language) []QuestionFilter {
+	filters := []QuestionFilter{{Topic: topic, Language: language, State: Live}}
+	info, _ := GetTopic(topic)
+	for _, related := range info.RelatedTopics {
+		filters = append(filters, QuestionFilter{Topic: related, Language: language, State: Live})
+	}
+	if language != English {
+		filters = append(filters, QuestionFilter{Topic: topic, Language: English, State: Live})
+	}
+	return filters
+}
@@ -0,0 +1,100 @@
+package app
+
+import (
+	"log"
+	"sharequiz/app/database"
+	"strconv"
+	"time"
+)
+
+const (
+	//MinAttemptsForDifficulty answers needed before a question difficulty is calibrated
+	MinAttemptsForDifficulty = 20
+	//EasyCorrectRate correct answer rate at or above which a question is easy
+	EasyCorrectRate = 0.7
+	//HardCorrectRate correct answer rate below which a question is hard
+	HardCorrectRate = 0.4
+	//pendingCalibrationKey set of the questions answered since the last calibration
+	pendingCalibrationKey = "question-stats-pending"
+)
+
+func questionStatsKey(questionID string) string {
+	return "question-stats-" + questionID
+}
+
+//RecordQuestionStats adds the answers of a finished game to the question stats
+func RecordQuestionStats(game *Game) {
+	for i, question := range game.Questions {
+		if i == 0 || question.ID == "" || len(question.PlayerAnswers) == 0 {
+			continue
+		}
+		attempts, correct := 0, 0
+		for playerID, isCorrect := range question.CorrectPlayers() {
+			if game.Skipped(playerID, i) {
+				continue
+			}
+			attempts++
+			if isCorrect {
+				correct++
+			}
+		}
+		if attempts == 0 {
+			continue
+		}
+		key := questionStatsKey(question.ID)
+		pipe := database.RedisClient.Pipeline()
+		pipe.HIncrBy(key, "attempts", int64(attempts))
+		pipe.HIncrBy(key, "correct", int64(correct))
+		pipe.SAdd(pendingCalibrationKey, question.ID)
+		if _, err := pipe.Exec(); err != nil {
+			log.Println("error while recording stats for question "+question.ID, err)
+		}
+	}
+}
+
+//DifficultyForRate difficulty of a question answered correctly at the rate
+func DifficultyForRate(correctRate float64) Difficulty {
+	if correctRate >= EasyCorrectRate {
+		return Easy
+	} else if correctRate < HardCorrectRate {
+		return Hard
+	}
+	return Medium
+}
+
+//CalibrateDifficulty recomputes the difficulty of the questions answered since
+//the last calibration from their correct answer rate
+func CalibrateDifficulty() {
+	ids, err := database.RedisClient.SMembers(pendingCalibrationKey).Result()
+	if err != nil {
+		log.Println("error while calibrating difficulty", err)
+		return
+	}
+	for _, id := range ids {
+		stats, err := database.RedisClient.HGetAll(questionStatsKey(id)).Result()
+		if err != nil {
+			log.Println("error while calibrating question "+id, err)
+			continue
+		}
+		attempts, _ := strconv.Atoi(stats["attempts"])
+		correct, _ := strconv.Atoi(stats["correct"])
+		if attempts < MinAttemptsForDifficulty {
+			continue
+		}
+		err = QuestionRepo.SetDifficulty(id, DifficultyForRate(float64(correct)/float64(attempts)))
+		if err != nil && err != ErrQuestionNotFound {
+			log.Println("error while calibrating question "+id, err)
+			continue
+		}
+		database.RedisClient.SRem(pendingCalibrationKey, id)
+	}
+}
+
+//StartDifficultyCalibration calibrates the question difficulty at every interval
+func StartDifficultyCalibration(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		CalibrateDifficulty()
+	}
+}
@@ -0,0 +1,108 @@
+package app
+
+import (
+	"math"
+	"strconv"
+	"strings"
+)
+
+//QuestionType decides how a question is shown and graded
+type QuestionType string
+
+//Question types, questions stored before the types are text questions
+const (
+	TextQuestion        QuestionType = ""
+	ImageQuestion       QuestionType = "image"
+	TrueFalseQuestion   QuestionType = "true_false"
+	MultiSelectQuestion QuestionType = "multi_select"
+	NumericQuestion     QuestionType = "numeric"
+)
+
+//MultiSelectSeparator separates the options of a multi select answer
+const MultiSelectSeparator = "|"
+
+//QuestionTypes all the question types
+var QuestionTypes = []QuestionType{TextQuestion, ImageQuestion, TrueFalseQuestion, MultiSelectQuestion, NumericQuestion}
+
+func validQuestionType(questionType QuestionType) bool {
+	for _, known := range QuestionTypes {
+		if known == questionType {
+			return true
+		}
+	}
+	return false
+}
+
+//splitAnswer options of a multi select answer
+func splitAnswer(answer string) map[string]bool {
+	options := make(map[string]bool)
+	for _, option := range strings.Split(answer, MultiSelectSeparator) {
+		if option = strings.TrimSpace(option); option != "" {
+			options[option] = true
+		}
+	}
+	return options
+}
+
+//numericDistance distance of the answer from the numeric answer of the
+//question, answers like Inf or NaN are not numbers
+func numericDistance(question Question, answer string) (float64, bool) {
+	expected, err := strconv.ParseFloat(strings.TrimSpace(question.Answer), 64)
+	if err != nil || math.IsInf(expected, 0) || math.IsNaN(expected) {
+		return 0, false
+	}
+	value, err := strconv.ParseFloat(strings.TrimSpace(answer), 64)
+	if err != nil || math.IsInf(value, 0) || math.IsNaN(value) {
+		return 0, false
+	}
+	return math.Abs(value - expected), true
+}
+
+//IsCorrect grades the answer on its own. Multi select answers need exactly
+//the correct options and numeric answers need to be within the tolerance.
+func (q Question) IsCorrect(answer string) bool {
+	switch q.Type {
+	case MultiSelectQuestion:
+		expected, given := splitAnswer(q.Answer), splitAnswer(answer)
+		if len(expected) != len(given) {
+			return false
+		}
+		for option := range expected {
+			if !given[option] {
+				return false
+			}
+		}
+		return true
+	case NumericQuestion:
+		distance, ok := numericDistance(q, answer)
+		return ok && distance <= q.Tolerance
+	}
+	return answer == q.Answer
+}
+
+//CorrectPlayers grades the answers of the players to the question, in a
+//numeric question answered with at least two numbers the players closest to
+//the answer win as well
+func (q Question) CorrectPlayers() map[string]bool {
+	correct := make(map[string]bool, len(q.PlayerAnswers))
+	closest := math.Inf(1)
+	numbers := 0
+	for playerID, answer := range q.PlayerAnswers {
+		correct[playerID] = q.IsCorrect(answer)
+		if q.Type != NumericQuestion {
+			continue
+		}
+		if distance, ok := numericDistance(q, answer); ok {
+			numbers++
+			closest = math.Min(closest, distance)
+		}
+	}
+	if q.Type == NumericQuestion && numbers > 1 {
+		for playerID, answer := range q.PlayerAnswers {
+			if distance, ok := numericDistance(q, answer); ok && distance == closest {
+				correct[playerID] = true
+			}
+		}
+	}
+	return correct
+}
@@ -0,0 +1,106 @@
+package app
+
+import (
+	"errors"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+const (
+	//MinOptions minimum number of options of a question
+	MinOptions = 2
+	//MaxOptions maximum number of options of a question
+	MaxOptions = 6
+)
+
+//ValidateQuestion checks a question before it is stored
+func ValidateQuestion(question Question) error {
+	if strings.TrimSpace(question.QuestionText) == "" {
+		return errors.New("question text is required")
+	}
+	if err := validateAnswer(question); err != nil {
+		return err
+	}
+	if err := validateExplanations(question.Explanations); err != nil {
+		return err
+	}
+	if len(question.Topics) == 0 {
+		return errors.New("at least one topic is required")
+	}
+	for _, topic := range question.Topics {
+		if _, ok := GetTopic(topic); !ok {
+			return errors.New("unknown topic")
+		}
+	}
+	if _, ok := GetLanguage(question.Language); !ok {
+		return errors.New("unknown language")
+	}
+	if question.Difficulty < 0 || question.Difficulty > Hard {
+		return errors.New("unknown difficulty")
+	}
+	if question.State < 0 || question.State > Retired {
+		return errors.New("unknown state")
+	}
+	return nil
+}
+
+//validateAnswer checks the options and the answer for the type of the question
+func validateAnswer(question Question) error {
+	if !validQuestionType(question.Type) {
+		return errors.New("unknown question type")
+	}
+	if question.Type == NumericQuestion {
+		if len(question.Options) > 0 {
+			return errors.New("numeric questions have no options")
+		}
+		if _, err := strconv.ParseFloat(strings.TrimSpace(question.Answer), 64); err != nil {
+			return errors.New("answer must be a number")
+		}
+		if question.Tolerance < 0 {
+			return errors.New("tolerance can not be negative")
+		}
+		return nil
+	}
+	if question.Type == ImageQuestion {
+		imageURL, err := url.Parse(question.ImageURL)
+		if err != nil || (imageURL.Scheme != "https" && !strings.HasPrefix(question.ImageURL, "/static/")) {
+			return errors.New("image questions need an https or static image url")
+		}
+	}
+	if question.Type == TrueFalseQuestion && len(question.Options) != 2 {
+		return errors.New("a true or false question needs 2 options")
+	}
+	if len(question.Options) < MinOptions || len(question.Options) > MaxOptions {
+		return errors.New("a question needs 2 to 6 options")
+	}
+	options := make(map[string]bool)
+	for _, option := range question.Options {
+		if strings.TrimSpace(option) == "" {
+			return errors.New("options can not be empty")
+		}
+		if options[option] {
+			return errors.New("duplicate option " + option)
+		}
+		if question.Type == MultiSelectQuestion && strings.Contains(option, MultiSelectSeparator) {
+			return errors.New("multi select options can not contain " + MultiSelectSeparator)
+		}
+		options[option] = true
+	}
+	if question.Type != MultiSelectQuestion {
+		if !options[question.Answer] {
+			return errors.New("answer must be one of the options")
+		}
+		return nil
+	}
+	answers := splitAnswer(question.Answer)
+	if len(answers) == 0 {
+		return errors.New("multi select questions need at least one correct option")
+	}
+	for answer := range answers {
+		if !options[answer] {
+			return errors.New("answer must be one of the options")
+		}
+	}
+	return nil
+}
@@ -8,87 +8,99 @@ import (
 	"strconv"
 
 	"github.com/gin-gonic/gin"
-	"github.com/go-redis/redis"
 )
 
-// GameRoom GameRoom data
+// GameRoom GameRoom data, only the players of a private room can join it
 type GameRoom struct {
 	Language Language `json:"language,string"`
 	Topic    Topic    `json:"topic,string"`
+	Players  []string `json:"players,omitempty"`
 }
 
 //CreateRoom create room for a game for other players to join.
 func CreateRoom(c *gin.Context) {
-	phoneNumber := c.Query("phone_number")
 	room := c.Query("room")
 	gameRoom := GameRoom{}
 	err := json.Unmarshal([]byte(room), &gameRoom)
-	if err != nil {
+	if err != nil || !TopicEnabled(gameRoom.Topic) || !LanguageEnabled(gameRoom.Language) {
 		sendError(c, "check the topic and room")
 		return
 	}
-	err = ValidatePhoneNumber(phoneNumber)
+	fmt.Println("creating room for the player " + CurrentUserID(c))
+	gameRoom.Players = nil
+	roomID, err := NewRoom(gameRoom)
 	if err != nil {
-		sendError(c, "check the phone number")
+		sendError(c, "error while creating room")
 		return
 	}
-	fmt.Println("creating room for the phone number " + phoneNumber)
-	roomID := 0
-	lastRoomID, err := database.RedisClient.Get(LastRoomIDKey).Result()
-	if err == redis.Nil {
-		roomID = 1
-	} else if err != nil {
-		sendError(c, "error while creating game ")
-		return
-	} else {
-		roomID, _ = strconv.Atoi(lastRoomID)
-		roomID++
+	sendSuccess(c, roomID)
+}
+
+//NewRoom stores the room and returns its id
+func NewRoom(gameRoom GameRoom) (string, error) {
+	roomID, err := database.RedisClient.Incr(LastRoomIDKey).Result()
+	if err != nil {
+		return "", err
 	}
 	gameRoomStr, err := json.Marshal(gameRoom)
 	if err != nil {
-		sendError(c, "error while creating room")
-		return
+		return "", err
 	}
-	_, err = database.RedisClient.Set(LastRoomIDKey, roomID, 0).Result()
+	_, err = database.RedisClient.Set("room-"+strconv.FormatInt(roomID, 10), gameRoomStr, 0).Result()
 	if err != nil {
-		sendError(c, "error while creating room")
-		return
+		return "", err
 	}
-	_, err = database.RedisClient.Set("room-"+strconv.Itoa(roomID), gameRoomStr, 0).Result()
+	return strconv.FormatInt(roomID, 10), nil
+}
+
+//GetRoom loads the room with the id
+func GetRoom(roomID string) (*GameRoom, error) {
+	savedRoomData, err := database.RedisClient.Get("room-" + roomID).Result()
 	if err != nil {
-		sendError(c, "error while creating room")
-		return
+		return nil, err
+	}
+	gameRoom := &GameRoom{}
+	if err := json.Unmarshal([]byte(savedRoomData), gameRoom); err != nil {
+		return nil, err
 	}
-	sendSuccess(c, strconv.Itoa(roomID))
+	return gameRoom, nil
 }
 
-//JoinRoom join room for a game.
+//CanJoin tells if the player can join the room
+func (r *GameRoom) CanJoin(userID string) bool {
+	if len(r.Players) == 0 {
+		return true
+	}
+	for _, player := range r.Players {
+		if player == userID {
+			return true
+		}
+	}
+	return false
+}
+
+//JoinRoom join room for a game by the logged in player.
 func JoinRoom(c *gin.Context) {
-	phoneNumber := c.Query("phone_number")
 	roomID := c.Query("roomID")
 	roomData := c.Query("room")
 	gameRoom := GameRoom{}
 
 	err := json.Unmarshal([]byte(roomData), &gameRoom)
-	if err != nil {
+	if err != nil || !TopicEnabled(gameRoom.Topic) || !LanguageEnabled(gameRoom.Language) {
 		sendError(c, "check the topic and room")
 		return
 	}
-	err = ValidatePhoneNumber(phoneNumber)
-	if err != nil {
-		sendError(c, "check the phone number")
-		return
-	}
-	fmt.Println("joining room " + roomID + " for the phone number " + phoneNumber)
-	savedRoomData, err := database.RedisClient.Get("room-" + roomID).Result()
+	fmt.Println("joining room " + roomID + " for the player " + CurrentUserID(c))
+	savedGameRoom, err := GetRoom(roomID)
 	if err != nil {
 		sendError(c, "error while joining game ")
 		return
 	}
-	savedGameRoom := GameRoom{}
-	err = json.Unmarshal([]byte(savedRoomData), &savedGameRoom)
-	if err != nil {
-		sendError(c, "error while joining game ")
+
+	if !savedGameRoom.CanJoin(CurrentUserID(c)) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": "room not found",
+		})
 		return
 	}
 
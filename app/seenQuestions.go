@@ -0,0 +1,69 @@
+package app
+
+import (
+	"log"
+	"sharequiz/app/database"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+const (
+	//SeenQuestionsLimit max number of questions remembered for a player
+	SeenQuestionsLimit = 500
+	//SeenQuestionsDays number of days a seen question is remembered
+	SeenQuestionsDays = 30
+)
+
+func seenQuestionsKey(playerID string) string {
+	return "seen-questions-" + playerID
+}
+
+//GetSeenQuestions returns the ids of the questions seen by the players
+func GetSeenQuestions(playerIDs []string) ([]string, error) {
+	seen := make([]string, 0)
+	unique := make(map[string]bool)
+	for _, playerID := range playerIDs {
+		ids, err := database.RedisClient.ZRange(seenQuestionsKey(playerID), 0, -1).Result()
+		if err != nil {
+			return nil, err
+		}
+		for _, id := range ids {
+			if !unique[id] {
+				unique[id] = true
+				seen = append(seen, id)
+			}
+		}
+	}
+	return seen, nil
+}
+
+//RecordSeenQuestions adds the questions to the history of the players, dropping
+//questions older than SeenQuestionsDays and beyond SeenQuestionsLimit
+func RecordSeenQuestions(playerIDs []string, questions []Question) {
+	now := time.Now()
+	members := make([]redis.Z, 0, len(questions))
+	for _, question := range questions {
+		if question.ID != "" {
+			members = append(members, redis.Z{Score: float64(now.Unix()), Member: question.ID})
+		}
+	}
+	if len(members) == 0 {
+		return
+	}
+	oldest := now.AddDate(0, 0, -SeenQuestionsDays).Unix()
+	for _, playerID := range playerIDs {
+		key := seenQuestionsKey(playerID)
+		_, err := database.RedisClient.Pipelined(func(pipe redis.Pipeliner) error {
+			pipe.ZAdd(key, members...)
+			pipe.ZRemRangeByScore(key, "-inf", "("+strconv.FormatInt(oldest, 10))
+			pipe.ZRemRangeByRank(key, 0, -SeenQuestionsLimit-1)
+			pipe.Expire(key, SeenQuestionsDays*24*time.Hour)
+			return nil
+		})
+		if err != nil {
+			log.Println("error while recording seen questions for "+playerID, err)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package socket
+
+import (
+	"errors"
+	"sharequiz/app"
+)
+
+//ErrGameNotActive returned for actions which need a running game
+var ErrGameNotActive = errors.New("game is not active")
+
+//ErrNotLiveGame returned for actions on async and daily games, they are only
+//run on live games
+var ErrNotLiveGame = errors.New("game is not a live game")
+
+//ErrGameFinished returned for changes to finished games, their stats and
+//leaderboards are already recorded
+var ErrGameFinished = errors.New("game is finished")
+
+//ForceAdvanceQuestion closes the current question of a stuck game like the
+//last answer does and moves to the next question, or finishes the game after
+//the last question
+func ForceAdvanceQuestion(gameID string, action app.GameAdminAction) (*app.Game, error) {
+	return runGameAction(gameID, func(game *app.Game) error {
+		if !isRunning(game) {
+			return ErrGameNotActive
+		}
+		action.Action = app.ActionForceAdvance
+		app.AddAdminAction(game, action)
+		if game.QuestionNumber > 0 {
+			app.ScoreQuestion(game, game.QuestionNumber)
+			app.PublishGameEvent(app.GameEvent{Type: app.EventQuestionClosed, GameID: game.ID, QuestionNumber: game.QuestionNumber})
+			sendQuestionResult(game, game.QuestionNumber)
+		}
+		if game.QuestionNumber >= game.MaxQuestions {
+			return finishGame(game)
+		}
+		game.QuestionNumber++
+		app.ShowQuestion(game)
+		if _, err := app.SaveGame(game); err != nil {
+			return err
+		}
+		broadcastGame(game, "new_question")
+		app.PublishGameEvent(app.GameEvent{Type: app.EventQuestionShown, GameID: game.ID, QuestionNumber: game.QuestionNumber})
+		return nil
+	})
+}
+
+//ForceFinishGame finishes a running or disconnected game with the results
+//computed so far
+func ForceFinishGame(gameID string, action app.GameAdminAction) (*app.Game, error) {
+	return runGameAction(gameID, func(game *app.Game) error {
+		if !isRunning(game) {
+			return ErrGameNotActive
+		}
+		action.Action = app.ActionForceFinish
+		app.AddAdminAction(game, action)
+		return finishGame(game)
+	})
+}
+
+//VoidGame finishes a running game without recording it in the stats
+func VoidGame(gameID string, action app.GameAdminAction) (*app.Game, error) {
+	return runGameAction(gameID, func(game *app.Game) error {
+		if !isRunning(game) {
+			return ErrGameFinished
+		}
+		game.Voided = true
+		action.Action = app.ActionVoid
+		app.AddAdminAction(game, action)
+		return finishGame(game)
+	})
+}
+
+//AdjustScore changes the score of a player of a running game by the delta of
+//the action
+func AdjustScore(gameID string, action app.GameAdminAction) (*app.Game, error) {
+	return runGameAction(gameID, func(game *app.Game) error {
+		if !isRunning(game) {
+			return ErrGameFinished
+		}
+		if _, ok := game.Players[action.PlayerID]; !ok {
+			return errors.New("player not in the game")
+		}
+		action.Action = app.ActionAdjustScore
+		app.AddAdminAction(game, action)
+		if _, err := app.SaveGame(game); err != nil {
+			return err
+		}
+		broadcastGame(game, "score_adjusted")
+		return nil
+	})
+}
+
+//runGameAction runs the action on the live game holding the room lock, the
+//lock is removed once the action finished the game
+func runGameAction(gameID string, run func(*app.Game) error) (*app.Game, error) {
+	lockRoom(gameID)
+	game, err := app.GetGame(gameID)
+	if err != nil {
+		unlockRoom(gameID)
+		return nil, err
+	}
+	if game.Mode != app.ModeLive {
+		unlockRoom(gameID)
+		return nil, ErrNotLiveGame
+	}
+	err = run(game)
+	unlockRoom(gameID)
+	if err != nil {
+		return nil, err
+	}
+	if game.Status == app.Finished {
+		deleteLockRoom(gameID)
+		clearReactions(gameID)
+	}
+	return game, nil
+}
+
+//isRunning checks if the game is still played or was left by a player
+func isRunning(game *app.Game) bool {
+	return game.Status == app.Active || game.Status == app.Disconnected
+}
+
+//finishGame finishes the game holding the room lock
+func finishGame(game *app.Game) error {
+	app.FinishGame(game)
+	if _, err := app.SaveGame(game); err != nil {
+		return err
+	}
+	broadcastGame(game, "game_over")
+	app.PublishGameEvent(app.GameEvent{Type: app.EventGameOver, GameID: game.ID, QuestionNumber: game.QuestionNumber})
+	go app.GameFinished(game)
+	return nil
+}
@@ -17,6 +17,7 @@ var playerJoinServer *socketio.Server
 type GameData struct {
 	Topic    app.Topic    `json:"topic,string"`
 	Language app.Language `json:"language,string"`
+	Token    string       `json:"token"`
 }
 
 // GameRoom Initial game data of the game
@@ -24,16 +25,23 @@ type GameRoom struct {
 	Topic    app.Topic    `json:"topic,string"`
 	Language app.Language `json:"language,string"`
 	RoomID   string       `json:"roomID"`
+	Token    string       `json:"token"`
 }
 
 // WaitingSockets variable is used for connection.
 var WaitingSockets = make(map[string][]socketio.Conn)
 
+//waitingMutex guards WaitingSockets, the queue of a topic is only changed
+//while holding its topic lock as well
+var waitingMutex sync.Mutex
+
 // SocketToTopicMap is a map from the socket id to the game topic
 var SocketToTopicMap = make(map[string]string)
 
-// RoomToLock Room locks for synchronization of go routines for a room.
+// RoomToLock Room locks for synchronization of go routines for a room, the map
+// itself is guarded by roomLocksMutex
 var RoomToLock = make(map[string]*sync.Mutex)
+var roomLocksMutex sync.Mutex
 
 // TopicToLock Topic locks for synchronization of go routines for a topic.
 var TopicToLock = make(map[string]*sync.Mutex)
@@ -59,12 +67,28 @@ func InitPlayerJoinSocket() {
 		go connectJoinWithRoom(c, gameData)
 	})
 
+	playerJoinServer.OnEvent("/", "register", func(c socketio.Conn, data RegisterData) {
+		log.Println("register")
+		go registerPlayer(c, data)
+	})
+
+	playerJoinServer.OnEvent("/", "heartbeat", func(c socketio.Conn) {
+		go heartbeat(c)
+	})
+
+	playerJoinServer.OnEvent("/", "watch_tournament", func(c socketio.Conn, tournamentID string) {
+		go watchTournament(c, tournamentID)
+	})
+
 	playerJoinServer.OnDisconnect("/", func(s socketio.Conn, reason string) {
 		log.Println("Disconnect")
 		go disconnectJoin(s)
+		go unregisterPlayer(s)
 	})
 
 	go playerJoinServer.Serve()
+	go deliverNotifications()
+	go deliverTournamentUpdates()
 	defer playerJoinServer.Close()
 
 	http.Handle("/socket.io/join_game/", playerJoinServer)
@@ -74,7 +98,10 @@ func InitPlayerJoinSocket() {
 
 func connectJoinWithoutRoom(conn socketio.Conn, gameData GameData) {
 	fmt.Println("connectjoin without Room")
-	key := gameData.Topic.String() + "_" + gameData.Language.String()
+	key := gameData.Topic.Key() + "_" + gameData.Language.Key()
+	if !setPlayerContext(conn, gameData.Token) {
+		return
+	}
 	lockTopic(key)
 	connectJoin(conn, key, gameData.Language, gameData.Topic)
 	unlockTopic(key)
@@ -83,32 +110,65 @@ func connectJoinWithoutRoom(conn socketio.Conn, gameData GameData) {
 
 func connectJoinWithRoom(conn socketio.Conn, gameData GameRoom) {
 	fmt.Println("connectjoin with Room")
-	key := gameData.Topic.String() + "_" + gameData.Language.String() + "_" + gameData.RoomID
+	key := gameData.Topic.Key() + "_" + gameData.Language.Key() + "_" + gameData.RoomID
+	if !setPlayerContext(conn, gameData.Token) {
+		return
+	}
+	room, err := app.GetRoom(gameData.RoomID)
+	if err != nil || !room.CanJoin(conn.Context().(string)) {
+		conn.Emit("join_error", "room not found")
+		return
+	}
 	lockTopic(key)
 	connectJoin(conn, key, gameData.Language, gameData.Topic)
 	unlockTopic(key)
 }
 
+//setPlayerContext stores the user id of the session token on the connection
+func setPlayerContext(conn socketio.Conn, token string) bool {
+	playerID, err := app.UserIDFromToken(token)
+	if err != nil {
+		conn.Emit("join_error", err.Error())
+		return false
+	}
+	conn.SetContext(playerID)
+	return true
+}
+
 func connectJoin(conn socketio.Conn, key string, language app.Language, topic app.Topic) {
+	if !app.TopicEnabled(topic) || !app.LanguageEnabled(language) {
+		conn.Emit("join_error", "check the topic and language")
+		return
+	}
 	SocketToTopicMap[conn.ID()] = key
 	defer handleConnectJoinError(conn, key)
-	if len(WaitingSockets[key]) == 0 {
-		WaitingSockets[key] = append(WaitingSockets[key], conn)
+	waitingMutex.Lock()
+	socketsForTopic := WaitingSockets[key]
+	if len(socketsForTopic) == 0 {
+		WaitingSockets[key] = append(socketsForTopic, conn)
+		waitingMutex.Unlock()
 	} else {
-		socketsForTopic := WaitingSockets[key]
+		waitingMutex.Unlock()
 		secondConn := socketsForTopic[0]
-		gameID, err := app.CreateGame(app.NumOfQuestionsInGame, language, 2, topic)
-		if err != nil {
+		gameID, err := app.CreateGame(app.NumOfQuestionsInGame, language, 2, topic, playerIDs(conn, secondConn))
+		if err == app.ErrNotEnoughQuestions {
+			conn.Emit("join_error", err.Error())
+			secondConn.Emit("join_error", err.Error())
+			removeWaitingSocket(key, secondConn)
+			return
+		} else if err != nil {
 			fmt.Println("error for game is ")
 			panic("Socket Error")
 		}
-		RoomToLock[gameID] = &sync.Mutex{}
+		roomLock(gameID)
 		conn.Emit("game", gameID)
 		secondConn.Emit("game", gameID)
+		waitingMutex.Lock()
 		WaitingSockets[key] = socketsForTopic[1:]
 		if len(WaitingSockets[key]) == 0 {
 			delete(WaitingSockets, key)
 		}
+		waitingMutex.Unlock()
 	}
 }
 
@@ -120,18 +180,25 @@ func disconnectJoin(conn socketio.Conn) {
 	lockTopic(key)
 	defer handleDisconnectJoinError(conn, key)
 	delete(SocketToTopicMap, conn.ID())
+	removeWaitingSocket(key, conn)
+	unlockTopic(key)
+}
+
+func removeWaitingSocket(key string, conn socketio.Conn) {
+	waitingMutex.Lock()
+	defer waitingMutex.Unlock()
 	socketsForTopic, ok := WaitingSockets[key]
 	if ok {
 		for i, value := range socketsForTopic {
 			if value.ID() == conn.ID() {
 				WaitingSockets[key] = append(socketsForTopic[:i], socketsForTopic[i+1:]...)
+				break
 			}
 		}
 	}
 	if len(WaitingSockets[key]) == 0 {
 		delete(WaitingSockets, key)
 	}
-	unlockTopic(key)
 }
 
 func handleConnectJoinError(conn socketio.Conn, key string) {
@@ -161,3 +228,36 @@ func unlockTopic(key string) {
 		mutex.Unlock()
 	}
 }
+
+//playerIDs ids of the players sent with the join events
+func playerIDs(conns ...socketio.Conn) []string {
+	ids := make([]string, 0, len(conns))
+	for _, conn := range conns {
+		if id, ok := conn.Context().(string); ok && id != "" {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+//WaitingPlayer player waiting in a matchmaking queue
+type WaitingPlayer struct {
+	SocketID string `json:"socketID"`
+	PlayerID string `json:"playerID"`
+}
+
+//WaitingQueues returns a snapshot of the players waiting in each matchmaking queue
+func WaitingQueues() map[string][]WaitingPlayer {
+	waitingMutex.Lock()
+	defer waitingMutex.Unlock()
+	queues := make(map[string][]WaitingPlayer)
+	for key, conns := range WaitingSockets {
+		players := make([]WaitingPlayer, 0, len(conns))
+		for _, conn := range conns {
+			playerID, _ := conn.Context().(string)
+			players = append(players, WaitingPlayer{SocketID: conn.ID(), PlayerID: playerID})
+		}
+		queues[key] = players
+	}
+	return queues
+}
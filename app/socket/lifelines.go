@@ -0,0 +1,65 @@
+package socket
+
+import (
+	"encoding/json"
+	"sharequiz/app"
+
+	socketio "github.com/googollee/go-socket.io"
+)
+
+//LifelineUsed lifeline use broadcast to the room, without the options of a 50:50
+type LifelineUsed struct {
+	PlayerID       string `json:"playerID"`
+	Lifeline       string `json:"lifeline"`
+	QuestionNumber int    `json:"questionNumber"`
+}
+
+//useLifeline applies the lifeline of the player to the current question, the
+//result goes only to the player and the room only learns which lifeline was used
+func useLifeline(c socketio.Conn, lifeline string) {
+	playerID, _ := c.Context().(string)
+	gameID, ok := clientToRoomMap[c.ID()]
+	if !ok || playerID == "" {
+		return
+	}
+	lockRoom(gameID)
+	game, err := app.GetGame(gameID)
+	if err != nil || game.Status != app.Active {
+		unlockRoom(gameID)
+		c.Emit("lifeline_error", "game is not active")
+		return
+	}
+	if _, ok := game.Players[playerID]; !ok {
+		unlockRoom(gameID)
+		return
+	}
+	use, err := app.UseLifeline(game, playerID, lifeline, game.QuestionNumber)
+	if err != nil {
+		unlockRoom(gameID)
+		c.Emit("lifeline_error", err.Error())
+		return
+	}
+	if _, err := app.SaveGame(game); err != nil {
+		unlockRoom(gameID)
+		c.Emit("lifeline_error", "error while using the lifeline")
+		return
+	}
+	useJSON, _ := json.Marshal(use)
+	c.Emit("lifeline", string(useJSON))
+	usedJSON, _ := json.Marshal(LifelineUsed{PlayerID: playerID, Lifeline: lifeline, QuestionNumber: use.QuestionNumber})
+	server.BroadcastToRoom("/", gameID, "lifeline_used", string(usedJSON))
+	app.PublishGameEvent(app.GameEvent{
+		Type:           app.EventLifeline,
+		GameID:         gameID,
+		PlayerID:       playerID,
+		QuestionNumber: use.QuestionNumber,
+		Data:           lifeline,
+	})
+	if lifeline != app.LifelineSkip {
+		unlockRoom(gameID)
+		return
+	}
+	defer handleAnswerQuestionError(c, gameID)
+	broadcastGame(game, "new_answer")
+	sendNewQuestion(game, false, c)
+}
@@ -0,0 +1,86 @@
+package socket
+
+import (
+	"encoding/json"
+	"log"
+	"sharequiz/app"
+	"sharequiz/app/database"
+	"sync"
+
+	socketio "github.com/googollee/go-socket.io"
+)
+
+//RegisterData sent by the app to receive notifications
+type RegisterData struct {
+	Token string `json:"token"`
+}
+
+var registeredConns = make(map[string][]socketio.Conn)
+var registeredLock sync.Mutex
+
+//registerPlayer keeps the connection of the logged in player for the
+//notifications and marks the player online
+func registerPlayer(conn socketio.Conn, data RegisterData) {
+	playerID, err := app.UserIDFromToken(data.Token)
+	if err != nil {
+		conn.Emit("register_error", err.Error())
+		return
+	}
+	conn.SetContext(playerID)
+	registeredLock.Lock()
+	registeredConns[playerID] = append(registeredConns[playerID], conn)
+	registeredLock.Unlock()
+	app.RefreshPresence(playerID)
+	conn.Emit("registered", playerID)
+}
+
+func heartbeat(conn socketio.Conn) {
+	if playerID, ok := conn.Context().(string); ok && playerID != "" {
+		app.RefreshPresence(playerID)
+	}
+}
+
+//unregisterPlayer removes the connection, the player goes offline with the
+//last connection unless they are in a game
+func unregisterPlayer(conn socketio.Conn) {
+	playerID, ok := conn.Context().(string)
+	if !ok || playerID == "" {
+		return
+	}
+	registeredLock.Lock()
+	conns := registeredConns[playerID]
+	for i, value := range conns {
+		if value.ID() == conn.ID() {
+			conns = append(conns[:i], conns[i+1:]...)
+			break
+		}
+	}
+	if len(conns) == 0 {
+		delete(registeredConns, playerID)
+	} else {
+		registeredConns[playerID] = conns
+	}
+	registeredLock.Unlock()
+	if len(conns) == 0 && app.GetPresences([]string{playerID})[playerID] == app.Online {
+		app.SetPresence(playerID, app.Offline)
+	}
+}
+
+//deliverNotifications sends the published notifications to the connected players
+func deliverNotifications() {
+	pubsub := database.RedisClient.Subscribe(app.NotificationsChannel)
+	defer pubsub.Close()
+	for message := range pubsub.Channel() {
+		notification := app.Notification{}
+		if err := json.Unmarshal([]byte(message.Payload), &notification); err != nil {
+			log.Println("bad notification", err)
+			continue
+		}
+		registeredLock.Lock()
+		conns := append([]socketio.Conn(nil), registeredConns[notification.UserID]...)
+		registeredLock.Unlock()
+		for _, conn := range conns {
+			conn.Emit("notification", message.Payload)
+		}
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"os"
 	"sharequiz/app"
 	"sharequiz/app/database"
+	"sync"
 	"time"
 
 	"github.com/go-redis/redis"
@@ -16,8 +17,8 @@ import (
 
 //Room to joined by the clients
 type Room struct {
-	Room        string `json:"room"`
-	PhoneNumber string `json:"phoneNumber"`
+	Room  string `json:"room"`
+	Token string `json:"token"`
 }
 
 var server *socketio.Server
@@ -39,6 +40,7 @@ func InitGameSocket() {
 	server.OnDisconnect("/", func(s socketio.Conn, reason string) {
 		log.Println("disconnect game")
 		go disconnectPlayer(s)
+		go removeSpectator(s)
 	})
 
 	server.OnEvent("/", "join", func(c socketio.Conn, room Room) {
@@ -46,11 +48,37 @@ func InitGameSocket() {
 		go playerJoin(c, room)
 	})
 
+	server.OnEvent("/", "spectate", func(c socketio.Conn, data SpectateData) {
+		log.Println("spectate game")
+		go spectateGame(c, data)
+	})
+
+	server.OnEvent("/", "lifeline", func(c socketio.Conn, lifeline string) {
+		go useLifeline(c, lifeline)
+	})
+
+	server.OnEvent("/", "react", func(c socketio.Conn, reaction string) {
+		go react(c, reaction)
+	})
+
+	server.OnEvent("/", "mute", func(c socketio.Conn, opponentID string) {
+		go mute(c, opponentID, true)
+	})
+
+	server.OnEvent("/", "unmute", func(c socketio.Conn, opponentID string) {
+		go mute(c, opponentID, false)
+	})
+
 	server.OnEvent("/", "answer", func(c socketio.Conn, gameString string) {
 		log.Println("answer")
 		go answerQuestion(c, gameString)
 	})
 
+	server.OnEvent("/", "report_question", func(c socketio.Conn, data app.ReportData) {
+		log.Println("report question")
+		go reportQuestion(c, data)
+	})
+
 	go server.Serve()
 	defer server.Close()
 
@@ -72,6 +100,7 @@ func disconnectPlayer(c socketio.Conn) {
 		delete(clientToRoomMap, clientID)
 	}
 	delete(roomToClientID, room)
+	clearReactions(room)
 	gameData, err := database.RedisClient.Get(room).Result()
 	if err != nil || err == redis.Nil {
 		log.Println(err)
@@ -89,7 +118,11 @@ func disconnectPlayer(c socketio.Conn) {
 		if err != nil {
 			panic(errorMessage)
 		}
-		server.BroadcastToRoom("/", room, "disconnect", string(gameJSON))
+		broadcastGame(game, "disconnect")
+		for playerID := range game.Players {
+			app.SetPresence(playerID, app.Online)
+		}
+		app.PublishGameEvent(app.GameEvent{Type: app.EventDisconnect, GameID: room, QuestionNumber: game.QuestionNumber})
 	}
 	unlockRoom(room)
 }
@@ -97,15 +130,19 @@ func disconnectPlayer(c socketio.Conn) {
 func playerJoin(c socketio.Conn, room Room) {
 	errorMessage := "error while joining player for the game"
 	roomString := string(room.Room)
+	playerID, err := app.UserIDFromToken(room.Token)
+	if err != nil {
+		c.Emit("join_error", err.Error())
+		return
+	}
+	profile, err := app.GetPublicProfile(playerID)
+	if err != nil {
+		c.Emit("join_error", errorMessage)
+		return
+	}
+	c.SetContext(playerID)
 	lockRoom(roomString)
 	defer handlePlayerJoinError(c, roomString)
-	c.Join(roomString)
-	clientToRoomMap[c.ID()] = roomString
-	clientIds, ok := roomToClientID[roomString]
-	if !ok {
-		clientIds = make([]string, 0)
-	}
-	roomToClientID[roomString] = append(clientIds, c.ID())
 	gameData, err := database.RedisClient.Get(roomString).Result()
 	if err != nil || err == redis.Nil {
 		log.Println(err)
@@ -113,18 +150,32 @@ func playerJoin(c socketio.Conn, room Room) {
 	}
 	game := &app.Game{}
 	err = json.Unmarshal([]byte(gameData), game)
-	if err != nil {
+	if err != nil || game.Mode != app.ModeLive {
 		panic(errorMessage)
 	}
+	if !game.CanJoin(playerID) {
+		unlockRoom(roomString)
+		c.Emit("join_error", "game not found")
+		return
+	}
+	c.Join(roomString)
+	clientToRoomMap[c.ID()] = roomString
+	clientIds, ok := roomToClientID[roomString]
+	if !ok {
+		clientIds = make([]string, 0)
+	}
+	roomToClientID[roomString] = append(clientIds, c.ID())
 	//1 is added as to use it as 1 indexed
 	scores := make([]int, game.MaxQuestions+1)
-	if _, ok := game.Players[room.PhoneNumber]; !ok {
-		game.Players[room.PhoneNumber] = app.Player{
-			ID:       room.PhoneNumber,
+	if _, ok := game.Players[playerID]; !ok {
+		game.Players[playerID] = app.Player{
+			ID:       playerID,
+			Name:     profile.DisplayName,
+			Avatar:   profile.Avatar,
 			Score:    0,
 			Selected: 0,
 		}
-		game.Scores[room.PhoneNumber] = scores
+		game.Scores[playerID] = scores
 	}
 	gameJSON, err := json.Marshal(game)
 	_, err = database.RedisClient.Set(roomString, string(gameJSON), 0).Result()
@@ -132,6 +183,10 @@ func playerJoin(c socketio.Conn, room Room) {
 		panic(errorMessage)
 	}
 	unlockRoom(roomString)
+	c.Emit("player", playerID)
+	addReactionConn(roomString, playerID, c)
+	app.SetPresence(playerID, app.InGame)
+	app.PublishGameEvent(app.GameEvent{Type: app.EventJoin, GameID: roomString, PlayerID: playerID})
 	if len(game.Players) == 2 {
 		go sendNewQuestion(game, true, c)
 	}
@@ -155,9 +210,35 @@ func answerQuestion(c socketio.Conn, gameString string) {
 	if err != nil {
 		panic(errorMessage)
 	}
-	for key, value := range game.Questions[game.QuestionNumber].PlayerAnswers {
-		oldGame.Questions[oldGame.QuestionNumber].PlayerAnswers[key] = value
-		oldGame.Scores[key] = game.Scores[key]
+	playerID, _ := c.Context().(string)
+	//only the first answer of a player to the current question of a running
+	//game counts
+	answered := false
+	if oldGame.Status == app.Active && game.QuestionNumber == oldGame.QuestionNumber &&
+		game.QuestionNumber > 0 && game.QuestionNumber < len(game.Questions) {
+		for key, value := range game.Questions[game.QuestionNumber].PlayerAnswers {
+			if _, ok := oldGame.Players[key]; !ok || key != playerID {
+				continue
+			}
+			if _, ok := oldGame.Questions[oldGame.QuestionNumber].PlayerAnswers[key]; ok {
+				continue
+			}
+			app.PublishGameEvent(app.GameEvent{
+				Type:           app.EventAnswer,
+				GameID:         game.ID,
+				PlayerID:       key,
+				QuestionNumber: oldGame.QuestionNumber,
+				Data:           value,
+			})
+			app.RecordAnswerTime(oldGame, key)
+			oldGame.Questions[oldGame.QuestionNumber].PlayerAnswers[key] = value
+			answered = true
+		}
+	}
+	if !answered {
+		unlockRoom(game.ID)
+		c.Emit("answer_error", "the answer is not accepted")
+		return
 	}
 	gameJSON, err := json.Marshal(oldGame)
 	if err != nil {
@@ -167,16 +248,34 @@ func answerQuestion(c socketio.Conn, gameString string) {
 	if err != nil {
 		panic(errorMessage)
 	}
-	server.BroadcastToRoom("/", game.ID, "new_answer", string(gameJSON))
+	broadcastGame(oldGame, "new_answer")
 	sendNewQuestion(oldGame, false, c)
 }
 
+func reportQuestion(c socketio.Conn, data app.ReportData) {
+	playerID, ok := c.Context().(string)
+	if !ok {
+		c.Emit("report_error", app.ErrInvalidSession.Error())
+		return
+	}
+	report, err := app.SaveQuestionReport(playerID, data)
+	if err != nil {
+		c.Emit("report_error", err.Error())
+		return
+	}
+	c.Emit("question_reported", report.ID)
+}
+
 func sendNewQuestion(game *app.Game, shouldLockRoom bool, c socketio.Conn) {
 	if shouldLockRoom {
 		lockRoom(game.ID)
 		defer handleSendNewQuestionError(c, game.ID)
 	}
 	errorMessage := "error while sending a new question for game "
+	if game.Status != app.Active {
+		unlockRoom(game.ID)
+		return
+	}
 	fmt.Println("send new question:", game.QuestionNumber, (game.Questions[game.QuestionNumber].PlayerAnswers))
 	event := "new_question"
 	questionNumber := game.QuestionNumber
@@ -186,12 +285,18 @@ func sendNewQuestion(game *app.Game, shouldLockRoom bool, c socketio.Conn) {
 		totalAnswered++
 	}
 	if totalAnswered == game.NumberOfPlayers || questionNumber == 0 {
+		if questionNumber > 0 {
+			app.ScoreQuestion(game, questionNumber)
+			app.PublishGameEvent(app.GameEvent{Type: app.EventQuestionClosed, GameID: game.ID, QuestionNumber: questionNumber})
+			sendQuestionResult(game, questionNumber)
+		}
 		if game.QuestionNumber == game.MaxQuestions {
-			game.Status = app.Finished
+			app.FinishGame(game)
 			event = "game_over"
 		} else {
 			game.QuestionNumber++
 			time.Sleep(2 * time.Second)
+			app.ShowQuestion(game)
 		}
 		gameJSON, err := json.Marshal(game)
 		if err != nil {
@@ -202,12 +307,47 @@ func sendNewQuestion(game *app.Game, shouldLockRoom bool, c socketio.Conn) {
 			panic(errorMessage)
 		}
 		fmt.Println("Sending new question" + game.ID)
-		server.BroadcastToRoom("/", game.ID, event, string(gameJSON))
+		broadcastGame(game, event)
+		if event == "game_over" {
+			app.PublishGameEvent(app.GameEvent{Type: app.EventGameOver, GameID: game.ID, QuestionNumber: game.QuestionNumber})
+		} else {
+			app.PublishGameEvent(app.GameEvent{Type: app.EventQuestionShown, GameID: game.ID, QuestionNumber: game.QuestionNumber})
+		}
 	}
 	unlockRoom(game.ID)
 	if event == "game_over" {
 		deleteLockRoom(game.ID)
+		clearReactions(game.ID)
+		app.GameFinished(game)
+	}
+}
+
+//QuestionResult sent to the room when a question closes
+type QuestionResult struct {
+	QuestionNumber int              `json:"questionNumber"`
+	Answer         string           `json:"answer"`
+	Correct        map[string]bool  `json:"correct"`
+	Explanation    *app.Explanation `json:"explanation,omitempty"`
+}
+
+//sendQuestionResult sends the answer, who was right and the explanation of
+//the closed question to the players and later to the spectators
+func sendQuestionResult(game *app.Game, questionNumber int) {
+	question := game.Questions[questionNumber]
+	result := QuestionResult{
+		QuestionNumber: questionNumber,
+		Answer:         question.Answer,
+		Correct:        question.CorrectPlayers(),
+		Explanation:    question.ExplanationFor(game.Language),
+	}
+	resultJSON, err := json.Marshal(result)
+	if err != nil {
+		return
 	}
+	server.BroadcastToRoom("/", game.ID, "question_result", string(resultJSON))
+	time.AfterFunc(SpectatorDelay, func() {
+		server.BroadcastToRoom("/", spectatorRoom(game.ID), "question_result", string(resultJSON))
+	})
 }
 
 func handleSendNewQuestionError(c socketio.Conn, room string) {
@@ -237,18 +377,34 @@ func handleDisconnectError(c socketio.Conn, room string) {
 	}
 }
 
-func lockRoom(roomID string) {
-	if mutex, ok := RoomToLock[roomID]; ok {
-		mutex.Lock()
+//roomLock returns the lock of the room, games created outside the matchmaking
+//like the tournament games get their lock on first use
+func roomLock(roomID string) *sync.Mutex {
+	roomLocksMutex.Lock()
+	defer roomLocksMutex.Unlock()
+	mutex, ok := RoomToLock[roomID]
+	if !ok {
+		mutex = &sync.Mutex{}
+		RoomToLock[roomID] = mutex
 	}
+	return mutex
+}
+
+func lockRoom(roomID string) {
+	roomLock(roomID).Lock()
 }
 
 func unlockRoom(roomID string) {
-	if mutex, ok := RoomToLock[roomID]; ok {
+	roomLocksMutex.Lock()
+	mutex, ok := RoomToLock[roomID]
+	roomLocksMutex.Unlock()
+	if ok {
 		mutex.Unlock()
 	}
 }
 
 func deleteLockRoom(roomID string) {
+	roomLocksMutex.Lock()
 	delete(RoomToLock, roomID)
+	roomLocksMutex.Unlock()
 }
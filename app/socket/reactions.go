@@ -0,0 +1,153 @@
+package socket
+
+import (
+	"encoding/json"
+	"sharequiz/app"
+	"sync"
+	"time"
+
+	socketio "github.com/googollee/go-socket.io"
+)
+
+//Reactions emoji and quick phrase ids a player can send, the client shows
+//the phrases in the language of the player
+var Reactions = map[string]bool{
+	"👍": true, "👏": true, "😂": true, "😮": true, "😢": true, "🔥": true,
+	"good_luck": true, "well_played": true, "nice": true, "oops": true, "thanks": true, "gg": true,
+}
+
+const (
+	//MaxReactions reactions a player can send within ReactionWindow
+	MaxReactions = 3
+	//ReactionWindow window of the reaction rate limit
+	ReactionWindow = 5 * time.Second
+)
+
+//Reaction sent to the players of the game
+type Reaction struct {
+	PlayerID string `json:"playerID"`
+	Reaction string `json:"reaction"`
+}
+
+//gameReactions connections, mutes and recent reactions of the players of a game
+type gameReactions struct {
+	conns  map[string]socketio.Conn
+	muted  map[string]map[string]bool
+	recent map[string][]time.Time
+}
+
+var reactionsLock sync.Mutex
+var reactionsByGame = make(map[string]*gameReactions)
+
+func reactionsFor(gameID string) *gameReactions {
+	reactions, ok := reactionsByGame[gameID]
+	if !ok {
+		reactions = &gameReactions{
+			conns:  make(map[string]socketio.Conn),
+			muted:  make(map[string]map[string]bool),
+			recent: make(map[string][]time.Time),
+		}
+		reactionsByGame[gameID] = reactions
+	}
+	return reactions
+}
+
+//addReactionConn keeps the connection of the player to deliver the reactions
+func addReactionConn(gameID string, playerID string, c socketio.Conn) {
+	reactionsLock.Lock()
+	reactionsFor(gameID).conns[playerID] = c
+	reactionsLock.Unlock()
+}
+
+func clearReactions(gameID string) {
+	reactionsLock.Lock()
+	delete(reactionsByGame, gameID)
+	reactionsLock.Unlock()
+}
+
+//allowReaction applies the rate limit of the player
+func (r *gameReactions) allowReaction(playerID string, now time.Time) bool {
+	recent := make([]time.Time, 0, MaxReactions)
+	for _, sent := range r.recent[playerID] {
+		if now.Sub(sent) < ReactionWindow {
+			recent = append(recent, sent)
+		}
+	}
+	if len(recent) >= MaxReactions {
+		r.recent[playerID] = recent
+		return false
+	}
+	r.recent[playerID] = append(recent, now)
+	return true
+}
+
+//react sends the reaction of the player to the players who did not mute
+//them and to the spectators, and records it in the game event log. Reactions
+//are only sent while the game is played.
+func react(c socketio.Conn, reaction string) {
+	playerID, _ := c.Context().(string)
+	gameID, ok := clientToRoomMap[c.ID()]
+	if !ok || playerID == "" {
+		return
+	}
+	if !Reactions[reaction] {
+		c.Emit("reaction_error", "unknown reaction")
+		return
+	}
+	if game, err := app.GetGame(gameID); err != nil || game.Status != app.Active {
+		c.Emit("reaction_error", "game is not active")
+		return
+	}
+	reactionsLock.Lock()
+	reactions, ok := reactionsByGame[gameID]
+	if !ok {
+		reactionsLock.Unlock()
+		c.Emit("reaction_error", "game is not active")
+		return
+	}
+	if !reactions.allowReaction(playerID, time.Now()) {
+		reactionsLock.Unlock()
+		c.Emit("reaction_error", "too many reactions")
+		return
+	}
+	receivers := make([]socketio.Conn, 0, len(reactions.conns))
+	for otherID, conn := range reactions.conns {
+		if !reactions.muted[otherID][playerID] {
+			receivers = append(receivers, conn)
+		}
+	}
+	reactionsLock.Unlock()
+
+	reactionJSON, err := json.Marshal(Reaction{PlayerID: playerID, Reaction: reaction})
+	if err != nil {
+		return
+	}
+	for _, conn := range receivers {
+		conn.Emit("reaction", string(reactionJSON))
+	}
+	time.AfterFunc(SpectatorDelay, func() {
+		server.BroadcastToRoom("/", spectatorRoom(gameID), "reaction", string(reactionJSON))
+	})
+	app.PublishGameEvent(app.GameEvent{Type: app.EventReaction, GameID: gameID, PlayerID: playerID, Data: reaction})
+}
+
+//mute stops or, with muted false, restarts the reactions of the opponent
+func mute(c socketio.Conn, opponentID string, muted bool) {
+	playerID, _ := c.Context().(string)
+	gameID, ok := clientToRoomMap[c.ID()]
+	if !ok || playerID == "" {
+		return
+	}
+	reactionsLock.Lock()
+	reactions, ok := reactionsByGame[gameID]
+	if !ok {
+		reactionsLock.Unlock()
+		return
+	}
+	if reactions.muted[playerID] == nil {
+		reactions.muted[playerID] = make(map[string]bool)
+	}
+	reactions.muted[playerID][opponentID] = muted
+	reactionsLock.Unlock()
+	c.Emit("muted", opponentID, muted)
+}
@@ -0,0 +1,162 @@
+package socket
+
+import (
+	"encoding/json"
+	"sharequiz/app"
+	"sync"
+	"time"
+
+	socketio "github.com/googollee/go-socket.io"
+)
+
+//SpectatorDelay delay of the game events sent to the spectators
+const SpectatorDelay = 3 * time.Second
+
+//SpectateData sent by a logged in player to watch a game
+type SpectateData struct {
+	Room  string `json:"room"`
+	Token string `json:"token"`
+}
+
+var spectatorsLock sync.Mutex
+var spectatorsByGame = make(map[string]map[string]bool)
+var clientToSpectatedGame = make(map[string]string)
+
+func spectatorRoom(gameID string) string {
+	return "spectate-" + gameID
+}
+
+//spectateGame joins the client to the read only room of the game
+func spectateGame(c socketio.Conn, data SpectateData) {
+	userID, err := app.UserIDFromToken(data.Token)
+	if err != nil {
+		c.Emit("spectate_error", err.Error())
+		return
+	}
+	game, err := app.GetGame(data.Room)
+	if err != nil || game.Mode != app.ModeLive {
+		c.Emit("spectate_error", "game not found")
+		return
+	}
+	if _, ok := game.Players[userID]; ok {
+		c.Emit("spectate_error", "players cannot spectate their game")
+		return
+	}
+	if !canSpectate(game, userID) {
+		c.Emit("spectate_error", "game not found")
+		return
+	}
+	c.SetContext(userID)
+	c.Join(spectatorRoom(game.ID))
+	spectatorsLock.Lock()
+	if spectatorsByGame[game.ID] == nil {
+		spectatorsByGame[game.ID] = make(map[string]bool)
+	}
+	spectatorsByGame[game.ID][c.ID()] = true
+	clientToSpectatedGame[c.ID()] = game.ID
+	count := len(spectatorsByGame[game.ID])
+	spectatorsLock.Unlock()
+	//the first view is delayed like the broadcasts so it is not ahead of them
+	if viewJSON, err := json.Marshal(spectatorView(game)); err == nil {
+		time.AfterFunc(SpectatorDelay, func() {
+			c.Emit("game", string(viewJSON))
+		})
+	}
+	broadcastSpectatorCount(game.ID, count)
+}
+
+//canSpectate tells if the user can watch the game, tournament games are open
+//to every player and other games only to the friends of their players
+func canSpectate(game *app.Game, userID string) bool {
+	if app.IsTournamentGame(game.ID) {
+		return true
+	}
+	for playerID := range game.Players {
+		if app.AreFriends(userID, playerID) {
+			return true
+		}
+	}
+	return false
+}
+
+//removeSpectator removes the disconnected client from the spectators
+func removeSpectator(c socketio.Conn) {
+	spectatorsLock.Lock()
+	gameID, ok := clientToSpectatedGame[c.ID()]
+	if !ok {
+		spectatorsLock.Unlock()
+		return
+	}
+	delete(clientToSpectatedGame, c.ID())
+	delete(spectatorsByGame[gameID], c.ID())
+	count := len(spectatorsByGame[gameID])
+	if count == 0 {
+		delete(spectatorsByGame, gameID)
+	}
+	spectatorsLock.Unlock()
+	broadcastSpectatorCount(gameID, count)
+}
+
+func broadcastSpectatorCount(gameID string, count int) {
+	server.BroadcastToRoom("/", gameID, "spectators", count)
+	server.BroadcastToRoom("/", spectatorRoom(gameID), "spectators", count)
+}
+
+//broadcastGame sends the game event to the players and after SpectatorDelay
+//to the spectators, neither of them gets the answers of the open questions
+func broadcastGame(game *app.Game, event string) {
+	if playerJSON, err := json.Marshal(playerView(game)); err == nil {
+		server.BroadcastToRoom("/", game.ID, event, string(playerJSON))
+	}
+	viewJSON, err := json.Marshal(spectatorView(game))
+	if err != nil {
+		return
+	}
+	time.AfterFunc(SpectatorDelay, func() {
+		server.BroadcastToRoom("/", spectatorRoom(game.ID), event, string(viewJSON))
+	})
+}
+
+//playerView copy of the game for the players, the open question is sent
+//without its answer and the questions not shown yet are left out until the
+//game is finished
+func playerView(game *app.Game) app.Game {
+	return gameView(game, true)
+}
+
+//spectatorView copy of the game for the spectators, the answers of the open
+//question and the questions not shown yet are left out until the game is
+//finished
+func spectatorView(game *app.Game) app.Game {
+	return gameView(game, false)
+}
+
+//gameView copy of the game with the closed questions only, the answers of the
+//players to the open question are kept with keepPlayerAnswers
+func gameView(game *app.Game, keepPlayerAnswers bool) app.Game {
+	view := *game
+	view.Questions = make([]app.Question, len(game.Questions))
+	for i, question := range game.Questions {
+		closed := i < game.QuestionNumber || game.Status == app.Finished
+		switch {
+		case closed:
+			view.Questions[i] = question
+		case i == game.QuestionNumber:
+			view.Questions[i] = app.Question{
+				ID:            question.ID,
+				Type:          question.Type,
+				QuestionText:  question.QuestionText,
+				ImageURL:      question.ImageURL,
+				Options:       question.Options,
+				PlayerAnswers: make(map[string]string),
+			}
+			if keepPlayerAnswers {
+				view.Questions[i].PlayerAnswers = question.PlayerAnswers
+				view.Questions[i].AnswerTimes = question.AnswerTimes
+			}
+		default:
+			view.Questions[i] = app.Question{PlayerAnswers: make(map[string]string)}
+		}
+	}
+	return view
+}
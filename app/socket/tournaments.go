@@ -0,0 +1,43 @@
+package socket
+
+import (
+	"encoding/json"
+	"log"
+	"sharequiz/app"
+	"sharequiz/app/database"
+
+	socketio "github.com/googollee/go-socket.io"
+)
+
+func tournamentRoom(tournamentID string) string {
+	return "tournament-" + tournamentID
+}
+
+//watchTournament sends the bracket of the tournament and its updates to the client
+func watchTournament(conn socketio.Conn, tournamentID string) {
+	tournament, err := app.GetTournament(tournamentID)
+	if err != nil {
+		conn.Emit("tournament_error", err.Error())
+		return
+	}
+	conn.Join(tournamentRoom(tournamentID))
+	tournamentJSON, err := json.Marshal(tournament)
+	if err != nil {
+		return
+	}
+	conn.Emit("tournament_update", string(tournamentJSON))
+}
+
+//deliverTournamentUpdates broadcasts the published brackets to the watching clients
+func deliverTournamentUpdates() {
+	pubsub := database.RedisClient.Subscribe(app.TournamentUpdatesChannel)
+	defer pubsub.Close()
+	for message := range pubsub.Channel() {
+		tournament := app.Tournament{}
+		if err := json.Unmarshal([]byte(message.Payload), &tournament); err != nil {
+			log.Println("bad tournament update", err)
+			continue
+		}
+		playerJoinServer.BroadcastToRoom("/", tournamentRoom(tournament.ID), "tournament_update", message.Payload)
+	}
+}
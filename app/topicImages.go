@@ -0,0 +1,200 @@
+package app
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"image"
+	"image/png"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"sharequiz/app/database"
+	"sharequiz/app/imaging"
+	"strconv"
+	"strings"
+
+	//decoders of the uploaded topic images
+	_ "image/gif"
+	_ "image/jpeg"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	//TopicImagesPath url path of the topic image variants
+	TopicImagesPath = "/static/topics/"
+	//TopicImageSize size in pixels of the 1x topic image
+	TopicImageSize = 96
+	//MaxTopicImageBytes largest topic image accepted for upload
+	MaxTopicImageBytes = 10 << 20
+	//MaxTopicImageSide largest width and height of an uploaded topic image
+	MaxTopicImageSide = 4096
+)
+
+//TopicImageDensities screen densities for which topic image variants are generated
+var TopicImageDensities = []int{1, 2, 3}
+
+//ImageVariant resized topic image for a screen density
+type ImageVariant struct {
+	Density int    `json:"density"`
+	Format  string `json:"format"`
+	Width   int    `json:"width"`
+	Height  int    `json:"height"`
+	URL     string `json:"url"`
+}
+
+//TopicResponse topic sent to the clients with the image urls for their density
+type TopicResponse struct {
+	TopicInfo
+	ImageURLs map[string]string `json:"imageURLs,omitempty"`
+}
+
+//topicImageFormats content types of the stored topic image formats
+var topicImageFormats = map[string]string{
+	"webp": "image/webp",
+	"png":  "image/png",
+}
+
+//topicImageKey stores a topic image variant in redis, so that every server
+//serves the images uploaded to any of them
+func topicImageKey(name string) string {
+	return "topic-image-" + name
+}
+
+//SaveTopicImage decodes the uploaded image and stores a webp and a png variant
+//for every density, named by the topic id and the hash of their content. The
+//variants of the previous image are removed.
+func SaveTopicImage(topic Topic, reader io.Reader) (TopicInfo, error) {
+	info, ok := GetTopic(topic)
+	if !ok {
+		return TopicInfo{}, errors.New("topic not found")
+	}
+	data, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return TopicInfo{}, errors.New("check the image")
+	}
+	//the size is checked before decoding, a small file can decode to a huge image
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return TopicInfo{}, errors.New("image should be a png, jpeg or gif")
+	}
+	if config.Width > MaxTopicImageSide || config.Height > MaxTopicImageSide {
+		return TopicInfo{}, errors.New("image should be at most 4096 x 4096 pixels")
+	}
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return TopicInfo{}, errors.New("image should be a png, jpeg or gif")
+	}
+	variants := make([]ImageVariant, 0, 2*len(TopicImageDensities))
+	names := make(map[string]bool)
+	for _, density := range TopicImageDensities {
+		width, height := imaging.Fit(src.Bounds(), TopicImageSize*density)
+		resized := imaging.Resize(src, width, height)
+		for _, format := range []string{"webp", "png"} {
+			var buf bytes.Buffer
+			if format == "webp" {
+				err = imaging.EncodeWebP(&buf, resized)
+			} else {
+				err = png.Encode(&buf, resized)
+			}
+			if err != nil {
+				return TopicInfo{}, err
+			}
+			hash := sha256.Sum256(buf.Bytes())
+			name := "topic-" + strconv.Itoa(int(topic)) + "-" + strconv.Itoa(density) + "x-" +
+				hex.EncodeToString(hash[:8]) + "." + format
+			if err := database.RedisClient.Set(topicImageKey(name), buf.Bytes(), 0).Err(); err != nil {
+				return TopicInfo{}, err
+			}
+			names[name] = true
+			variants = append(variants, ImageVariant{
+				Density: density,
+				Format:  format,
+				Width:   width,
+				Height:  height,
+				URL:     TopicImagesPath + name,
+			})
+		}
+	}
+	previous := info.Images
+	info.Images = variants
+	info, err = SaveTopic(info)
+	if err != nil {
+		return TopicInfo{}, err
+	}
+	for _, variant := range previous {
+		if name := filepath.Base(variant.URL); !names[name] {
+			database.RedisClient.Del(topicImageKey(name))
+		}
+	}
+	return info, nil
+}
+
+//ServeTopicImage serves a topic image variant, the names change with the
+//content so they are cached for a year
+func ServeTopicImage(c *gin.Context) {
+	name := filepath.Base(c.Param("name"))
+	contentType, ok := topicImageFormats[strings.TrimPrefix(filepath.Ext(name), ".")]
+	if !ok {
+		c.Status(http.StatusNotFound)
+		return
+	}
+	data, err := database.RedisClient.Get(topicImageKey(name)).Bytes()
+	if err != nil {
+		c.Status(http.StatusNotFound)
+		return
+	}
+	c.Header("Cache-Control", "public, max-age=31536000, immutable")
+	c.Data(http.StatusOK, contentType, data)
+}
+
+//topicResponses adds the image urls of the density to the topics
+func topicResponses(topics []TopicInfo, density int) []TopicResponse {
+	responses := make([]TopicResponse, len(topics))
+	for i, topic := range topics {
+		responses[i] = TopicResponse{TopicInfo: topic, ImageURLs: imageURLs(topic.Images, density)}
+	}
+	return responses
+}
+
+//imageURLs returns the url of each format for the smallest density at least
+//the requested density, or the largest density available
+func imageURLs(variants []ImageVariant, density int) map[string]string {
+	if len(variants) == 0 {
+		return nil
+	}
+	chosen := 0
+	for _, variant := range variants {
+		switch {
+		case chosen == 0:
+			chosen = variant.Density
+		case chosen < density && variant.Density > chosen:
+			chosen = variant.Density
+		case variant.Density >= density && variant.Density < chosen:
+			chosen = variant.Density
+		}
+	}
+	urls := make(map[string]string)
+	for _, variant := range variants {
+		if variant.Density == chosen {
+			urls[variant.Format] = variant.URL
+		}
+	}
+	return urls
+}
+
+//parseDensity reads densities like 2, 2x or 2.5 rounding up, defaulting to 1
+func parseDensity(value string) int {
+	density, err := strconv.ParseFloat(strings.TrimSuffix(value, "x"), 64)
+	if err != nil || density <= 1 {
+		return 1
+	}
+	rounded := int(density)
+	if float64(rounded) < density {
+		rounded++
+	}
+	return rounded
+}
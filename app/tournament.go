@@ -0,0 +1,483 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"sharequiz/app/database"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis"
+)
+
+//Tournament status
+const (
+	TournamentRegistering = "registering"
+	TournamentRunning     = "running"
+	TournamentFinished    = "finished"
+	TournamentCancelled   = "cancelled"
+)
+
+//Match status
+const (
+	MatchWaiting  = "waiting"
+	MatchPlaying  = "playing"
+	MatchFinished = "finished"
+)
+
+const (
+	//TournamentUpdatesChannel redis channel of the bracket updates
+	TournamentUpdatesChannel = "tournament-updates"
+	//MatchTimeout time a tournament game can take before the leader advances
+	MatchTimeout = 15 * time.Minute
+	//MinTournamentPlayers players needed to start a tournament
+	MinTournamentPlayers = 2
+
+	//NotificationTournamentMatch next game of the player in a tournament
+	NotificationTournamentMatch = "tournament_match"
+	//NotificationTournamentResult tournament finished
+	NotificationTournamentResult = "tournament_result"
+
+	tournamentsKey      = "tournaments"
+	lastTournamentIDKey = "last_tournament_id_key"
+)
+
+//Errors of the tournaments
+var (
+	ErrTournamentNotFound = errors.New("tournament not found")
+	ErrRegistrationClosed = errors.New("registration is closed")
+	ErrTournamentFull     = errors.New("tournament is full")
+)
+
+var tournamentLock sync.Mutex
+
+//TournamentData sent by an admin to schedule a tournament
+type TournamentData struct {
+	Name           string   `json:"name"`
+	Topic          Topic    `json:"topic,string"`
+	Language       Language `json:"language,string"`
+	StartTimestamp int64    `json:"startTimestamp"`
+	MaxPlayers     int      `json:"maxPlayers"`
+}
+
+//Match pairing of a round, a match with a single player is a bye
+type Match struct {
+	Players  []string `json:"players"`
+	GameID   string   `json:"gameID,omitempty"`
+	Winner   string   `json:"winner,omitempty"`
+	Status   string   `json:"status"`
+	Deadline int64    `json:"deadline,omitempty"`
+}
+
+//Tournament single elimination tournament, the rounds hold the bracket
+type Tournament struct {
+	ID             string          `json:"id"`
+	Name           string          `json:"name"`
+	Topic          Topic           `json:"topic,string"`
+	Language       Language        `json:"language,string"`
+	StartTimestamp int64           `json:"startTimestamp"`
+	MaxPlayers     int             `json:"maxPlayers"`
+	Status         string          `json:"status"`
+	Players        []PublicProfile `json:"players"`
+	Rounds         [][]Match       `json:"rounds"`
+	Winner         string          `json:"winner,omitempty"`
+}
+
+func tournamentKey(tournamentID string) string {
+	return "tournament-" + tournamentID
+}
+
+func tournamentGameKey(gameID string) string {
+	return "tournament-game-" + gameID
+}
+
+//IsTournamentGame tells if the game is a match of a tournament
+func IsTournamentGame(gameID string) bool {
+	exists, err := database.RedisClient.Exists(tournamentGameKey(gameID)).Result()
+	return err == nil && exists == 1
+}
+
+//CreateTournament schedules a tournament open for registration until it starts
+func CreateTournament(data TournamentData) (*Tournament, error) {
+	if data.Name == "" {
+		return nil, errors.New("name is needed")
+	}
+	if !TopicEnabled(data.Topic) || !LanguageEnabled(data.Language) {
+		return nil, errors.New("check the topic and language")
+	}
+	if data.MaxPlayers < MinTournamentPlayers {
+		return nil, errors.New("at least 2 players")
+	}
+	if data.StartTimestamp <= time.Now().Unix() {
+		return nil, errors.New("start should be in the future")
+	}
+	tournamentID, err := database.RedisClient.Incr(lastTournamentIDKey).Result()
+	if err != nil {
+		return nil, err
+	}
+	tournament := &Tournament{
+		ID:             strconv.FormatInt(tournamentID, 10),
+		Name:           data.Name,
+		Topic:          data.Topic,
+		Language:       data.Language,
+		StartTimestamp: data.StartTimestamp,
+		MaxPlayers:     data.MaxPlayers,
+		Status:         TournamentRegistering,
+		Players:        make([]PublicProfile, 0),
+		Rounds:         make([][]Match, 0),
+	}
+	if err := saveTournament(tournament); err != nil {
+		return nil, err
+	}
+	err = database.RedisClient.ZAdd(tournamentsKey, redis.Z{Score: float64(tournament.StartTimestamp), Member: tournament.ID}).Err()
+	return tournament, err
+}
+
+//GetTournament loads the tournament with the id
+func GetTournament(tournamentID string) (*Tournament, error) {
+	data, err := database.RedisClient.Get(tournamentKey(tournamentID)).Result()
+	if err == redis.Nil {
+		return nil, ErrTournamentNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	tournament := &Tournament{}
+	err = json.Unmarshal([]byte(data), tournament)
+	return tournament, err
+}
+
+//GetTournaments returns the tournaments by start time
+func GetTournaments() ([]*Tournament, error) {
+	tournamentIDs, err := database.RedisClient.ZRange(tournamentsKey, 0, -1).Result()
+	if err != nil {
+		return nil, err
+	}
+	tournaments := make([]*Tournament, 0, len(tournamentIDs))
+	for _, tournamentID := range tournamentIDs {
+		if tournament, err := GetTournament(tournamentID); err == nil {
+			tournaments = append(tournaments, tournament)
+		}
+	}
+	return tournaments, nil
+}
+
+//saveTournament stores the tournament and publishes it for the socket clients
+func saveTournament(tournament *Tournament) error {
+	data, err := json.Marshal(tournament)
+	if err != nil {
+		return err
+	}
+	pipe := database.RedisClient.Pipeline()
+	pipe.Set(tournamentKey(tournament.ID), string(data), 0)
+	pipe.Publish(TournamentUpdatesChannel, string(data))
+	_, err = pipe.Exec()
+	return err
+}
+
+//RegisterForTournament adds the player to the tournament
+func RegisterForTournament(tournamentID string, userID string) (*Tournament, error) {
+	tournamentLock.Lock()
+	defer tournamentLock.Unlock()
+	tournament, err := GetTournament(tournamentID)
+	if err != nil {
+		return nil, err
+	}
+	if tournament.Status != TournamentRegistering {
+		return nil, ErrRegistrationClosed
+	}
+	for _, player := range tournament.Players {
+		if player.UserID == userID {
+			return tournament, nil
+		}
+	}
+	if len(tournament.Players) >= tournament.MaxPlayers {
+		return nil, ErrTournamentFull
+	}
+	profile, err := GetPublicProfile(userID)
+	if err != nil {
+		return nil, err
+	}
+	tournament.Players = append(tournament.Players, profile)
+	return tournament, saveTournament(tournament)
+}
+
+//startTournament shuffles the players into the first round, byes go to the
+//players without an opponent
+func startTournament(tournament *Tournament) {
+	if len(tournament.Players) < MinTournamentPlayers {
+		tournament.Status = TournamentCancelled
+		return
+	}
+	playerIDs := make([]string, len(tournament.Players))
+	for i, player := range tournament.Players {
+		playerIDs[i] = player.UserID
+	}
+	rand.Shuffle(len(playerIDs), func(i, j int) {
+		playerIDs[i], playerIDs[j] = playerIDs[j], playerIDs[i]
+	})
+	size := 1
+	for size < len(playerIDs) {
+		size *= 2
+	}
+	round := make([]Match, size/2)
+	for i := range round {
+		round[i] = Match{Players: []string{playerIDs[i]}, Status: MatchWaiting}
+	}
+	for i, playerID := range playerIDs[len(round):] {
+		round[i].Players = append(round[i].Players, playerID)
+	}
+	tournament.Status = TournamentRunning
+	tournament.Rounds = append(tournament.Rounds, round)
+	startRound(tournament)
+}
+
+//startRound creates the games of the last round and advances the byes
+func startRound(tournament *Tournament) {
+	round := tournament.Rounds[len(tournament.Rounds)-1]
+	for i := range round {
+		match := &round[i]
+		if match.Status != MatchWaiting {
+			continue
+		}
+		if len(match.Players) == 1 {
+			match.Winner = match.Players[0]
+			match.Status = MatchFinished
+			continue
+		}
+		gameID, err := CreateGame(NumOfQuestionsInGame, tournament.Language, 2, tournament.Topic, match.Players)
+		if err != nil {
+			fmt.Println("error while creating the tournament game of " + tournament.ID)
+			continue
+		}
+		match.GameID = gameID
+		match.Status = MatchPlaying
+		match.Deadline = time.Now().Add(MatchTimeout).Unix()
+		database.RedisClient.Set(tournamentGameKey(gameID), tournament.ID, 0)
+		for _, playerID := range match.Players {
+			Notify(playerID, NotificationTournamentMatch, "", map[string]string{
+				"tournamentID": tournament.ID,
+				"gameID":       gameID,
+			})
+		}
+	}
+	advanceTournament(tournament)
+}
+
+//advanceTournament starts the next round once every match of the last round
+//has a winner
+func advanceTournament(tournament *Tournament) {
+	round := tournament.Rounds[len(tournament.Rounds)-1]
+	for _, match := range round {
+		if match.Status != MatchFinished {
+			return
+		}
+	}
+	if len(round) == 1 {
+		tournament.Winner = round[0].Winner
+		tournament.Status = TournamentFinished
+		for _, player := range tournament.Players {
+			Notify(player.UserID, NotificationTournamentResult, "", map[string]string{
+				"tournamentID": tournament.ID,
+				"winner":       tournament.Winner,
+			})
+		}
+		return
+	}
+	next := make([]Match, len(round)/2)
+	for i := range next {
+		next[i] = Match{Players: []string{round[2*i].Winner, round[2*i+1].Winner}, Status: MatchWaiting}
+	}
+	tournament.Rounds = append(tournament.Rounds, next)
+	startRound(tournament)
+}
+
+//matchWinner winner of a tournament game, a draw goes to the player with the
+//lowest total answer time, then to a random drawn player, and a player who
+//never joined loses
+func matchWinner(game *Game, match *Match) string {
+	if game.Winner != "" {
+		return game.Winner
+	}
+	results := ComputeResults(game)
+	if len(results) == 0 {
+		return match.Players[rand.Intn(len(match.Players))]
+	}
+	fastest := make([]string, 0, len(results))
+	var fastestTime int64
+	for _, result := range results {
+		if result.Score != results[0].Score {
+			break
+		}
+		total := totalAnswerTime(game, result.PlayerID)
+		if len(fastest) == 0 || total < fastestTime {
+			fastest = []string{result.PlayerID}
+			fastestTime = total
+		} else if total == fastestTime {
+			fastest = append(fastest, result.PlayerID)
+		}
+	}
+	return fastest[rand.Intn(len(fastest))]
+}
+
+//totalAnswerTime time the player took for every question of the game, an
+//unanswered question counts as the full answer time
+func totalAnswerTime(game *Game, playerID string) int64 {
+	var total int64
+	for number := 1; number <= game.MaxQuestions && number < len(game.Questions); number++ {
+		taken, ok := game.Questions[number].AnswerTimes[playerID]
+		if !ok {
+			taken = answerTimeLimit(game, playerID, number)
+		}
+		total += taken
+	}
+	return total
+}
+
+//TournamentGameFinished advances the winner of the tournament game, a voided
+//game is played again
+func TournamentGameFinished(game *Game) {
+	tournamentID, err := database.RedisClient.Get(tournamentGameKey(game.ID)).Result()
+	if err != nil {
+		return
+	}
+	tournamentLock.Lock()
+	defer tournamentLock.Unlock()
+	tournament, err := GetTournament(tournamentID)
+	if err != nil || tournament.Status != TournamentRunning {
+		return
+	}
+	round := tournament.Rounds[len(tournament.Rounds)-1]
+	for i := range round {
+		match := &round[i]
+		if match.GameID != game.ID || match.Status != MatchPlaying {
+			continue
+		}
+		if game.Voided {
+			match.Status = MatchWaiting
+			match.GameID = ""
+			startRound(tournament)
+		} else {
+			match.Winner = matchWinner(game, match)
+			match.Status = MatchFinished
+			advanceTournament(tournament)
+		}
+	}
+	saveTournament(tournament)
+}
+
+//RunTournaments starts the tournaments whose start time passed and decides
+//the matches past their deadline
+func RunTournaments() {
+	tournamentIDs, err := database.RedisClient.ZRangeByScore(tournamentsKey, redis.ZRangeBy{
+		Min: "-inf",
+		Max: fmt.Sprint(time.Now().Unix()),
+	}).Result()
+	if err != nil {
+		return
+	}
+	tournamentLock.Lock()
+	defer tournamentLock.Unlock()
+	for _, tournamentID := range tournamentIDs {
+		tournament, err := GetTournament(tournamentID)
+		if err != nil {
+			continue
+		}
+		switch tournament.Status {
+		case TournamentRegistering:
+			startTournament(tournament)
+		case TournamentRunning:
+			decideExpiredMatches(tournament)
+		default:
+			continue
+		}
+		saveTournament(tournament)
+	}
+}
+
+func decideExpiredMatches(tournament *Tournament) {
+	round := tournament.Rounds[len(tournament.Rounds)-1]
+	now := time.Now().Unix()
+	for i := range round {
+		match := &round[i]
+		if match.Status == MatchWaiting {
+			startRound(tournament)
+			return
+		}
+		if match.Status != MatchPlaying || match.Deadline > now {
+			continue
+		}
+		game, err := GetGame(match.GameID)
+		if err != nil {
+			continue
+		}
+		match.Winner = matchWinner(game, match)
+		match.Status = MatchFinished
+		RemoveActiveGame(game.ID)
+	}
+	advanceTournament(tournament)
+}
+
+//StartTournamentScheduler runs the tournaments at every interval
+func StartTournamentScheduler(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		RunTournaments()
+	}
+}
+
+//GetTournamentsHandler returns the tournaments
+func GetTournamentsHandler(c *gin.Context) {
+	tournaments, err := GetTournaments()
+	if err != nil {
+		sendError(c, "error while getting the tournaments")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"tournaments": tournaments,
+	})
+}
+
+//GetTournamentHandler returns the tournament with its bracket
+func GetTournamentHandler(c *gin.Context) {
+	tournament, err := GetTournament(c.Param("id"))
+	if err != nil {
+		sendTournamentError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"tournament": tournament,
+	})
+}
+
+//RegisterForTournamentHandler registers the logged in player for the tournament
+func RegisterForTournamentHandler(c *gin.Context) {
+	tournament, err := RegisterForTournament(c.Param("id"), CurrentUserID(c))
+	if err != nil {
+		sendTournamentError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"tournament": tournament,
+	})
+}
+
+func sendTournamentError(c *gin.Context, err error) {
+	switch err {
+	case ErrTournamentNotFound:
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": err.Error(),
+		})
+	case ErrRegistrationClosed, ErrTournamentFull:
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+	default:
+		sendError(c, err.Error())
+	}
+}
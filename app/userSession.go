@@ -0,0 +1,110 @@
+package app
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"sharequiz/app/database"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis"
+)
+
+const (
+	//SessionDuration time a player stays logged in after verifying the phone
+	SessionDuration = 30 * 24 * time.Hour
+	userContextKey  = "user-id"
+)
+
+//ErrInvalidSession returned for missing or expired session tokens
+var ErrInvalidSession = errors.New("login required")
+
+func userIDKey(phoneNumber string) string {
+	return "user-id-" + phoneNumber
+}
+
+func userPhoneKey(userID string) string {
+	return "user-phone-" + userID
+}
+
+func sessionKey(token string) string {
+	return "session-" + token
+}
+
+//GetOrCreateUserID returns the opaque user id of the phone number, creating
+//it for new players. Only called for verified phone numbers.
+func GetOrCreateUserID(phoneNumber string) (string, error) {
+	userID, err := database.RedisClient.Get(userIDKey(phoneNumber)).Result()
+	if err == nil {
+		return userID, nil
+	} else if err != redis.Nil {
+		return "", err
+	}
+	newID, err := randomHex(12)
+	if err != nil {
+		return "", err
+	}
+	created, err := database.RedisClient.SetNX(userIDKey(phoneNumber), newID, 0).Result()
+	if err != nil {
+		return "", err
+	}
+	if !created {
+		return database.RedisClient.Get(userIDKey(phoneNumber)).Result()
+	}
+	database.RedisClient.Set(userPhoneKey(newID), phoneNumber, 0)
+	return newID, nil
+}
+
+//CreateSession starts a session for the user and returns its token
+func CreateSession(userID string) (string, error) {
+	token, err := randomHex(32)
+	if err != nil {
+		return "", err
+	}
+	err = database.RedisClient.Set(sessionKey(token), userID, SessionDuration).Err()
+	return token, err
+}
+
+//UserIDFromToken returns the user of the session token
+func UserIDFromToken(token string) (string, error) {
+	if token == "" {
+		return "", ErrInvalidSession
+	}
+	userID, err := database.RedisClient.Get(sessionKey(token)).Result()
+	if err == redis.Nil {
+		return "", ErrInvalidSession
+	}
+	return userID, err
+}
+
+//AuthenticateUser loads the user of the bearer token of the request
+func AuthenticateUser() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+		userID, err := UserIDFromToken(token)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+				"message": "login required",
+			})
+			return
+		}
+		c.Set(userContextKey, userID)
+		c.Next()
+	}
+}
+
+//CurrentUserID user id set by AuthenticateUser
+func CurrentUserID(c *gin.Context) string {
+	return c.GetString(userContextKey)
+}
+
+func randomHex(size int) (string, error) {
+	data := make([]byte, size)
+	if _, err := rand.Read(data); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(data), nil
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sharequiz/app"
+	"sharequiz/app/database"
+)
+
+const usage = `usage:
+  sharequiz                                                  run the server
+  sharequiz import [-format csv|jsonl] [-dry-run] file       import questions
+  sharequiz export [-format csv|jsonl] [-topic n] [-language n] [file]
+                                                             export questions`
+
+//runCommand runs the command line subcommand in args, returns false when
+//there is no subcommand and the server should be started
+func runCommand(args []string) bool {
+	if len(args) == 0 {
+		return false
+	}
+	var err error
+	switch args[0] {
+	case "import":
+		err = importCommand(args[1:])
+	case "export":
+		err = exportCommand(args[1:])
+	default:
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	return true
+}
+
+func importCommand(args []string) error {
+	flags := flag.NewFlagSet("import", flag.ExitOnError)
+	format := flags.String("format", "", "csv or jsonl, defaults to the file extension")
+	dryRun := flags.Bool("dry-run", false, "only validate the questions")
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		return errors.New(usage)
+	}
+	fileName := flags.Arg(0)
+	if *format == "" {
+		*format = app.FormatFromFileName(fileName)
+	}
+	file, err := os.Open(fileName)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	database.InitRedis()
+	app.InitCatalog()
+	app.InitQuestionRepository()
+	report, err := app.ImportQuestions(file, *format, *dryRun)
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(report)
+}
+
+func exportCommand(args []string) error {
+	flags := flag.NewFlagSet("export", flag.ExitOnError)
+	format := flags.String("format", app.FormatJSONL, "csv or jsonl")
+	topic := flags.Int("topic", 0, "topic of the questions, all topics by default")
+	language := flags.Int("language", 0, "language of the questions, all languages by default")
+	flags.Parse(args)
+
+	var writer io.Writer = os.Stdout
+	if flags.NArg() > 0 {
+		file, err := os.Create(flags.Arg(0))
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		writer = file
+	}
+
+	database.InitRedis()
+	app.InitCatalog()
+	app.InitQuestionRepository()
+	filter := app.QuestionFilter{Topic: app.Topic(*topic), Language: app.Language(*language)}
+	return app.ExportQuestions(writer, *format, filter)
+}
@@ -8,4 +8,6 @@ require (
 	github.com/gin-gonic/gin v1.6.3
 	github.com/go-redis/redis v6.15.8+incompatible
 	github.com/googollee/go-socket.io v1.4.3
+	golang.org/x/crypto v0.0.0-20211117183948-ae814b36b871
+	gopkg.in/yaml.v2 v2.2.8
 )
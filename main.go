@@ -8,32 +8,109 @@ import (
 	"sharequiz/app/admin"
 	"sharequiz/app/database"
 	"sharequiz/app/socket"
+	"time"
 
 	"github.com/gin-contrib/static"
 	"github.com/gin-gonic/gin"
 )
 
 func main() {
+	if runCommand(os.Args[1:]) {
+		return
+	}
 	router := gin.Default()
 	router.Use(static.Serve("/static/images", static.LocalFile("./app/static/images", false)))
+	router.GET(app.TopicImagesPath+":name", app.ServeTopicImage)
 	router.GET("/ping", pong)
 	v1 := router.Group("/api/v1")
 	{
 		v1.GET("/otp", app.GetOTP)
 		v1.PUT("/otp", app.VerifyOTP)
-		v1.GET("/room", app.CreateRoom)
-		v1.GET("/join_room", app.JoinRoom)
+		v1.GET("/room", app.AuthenticateUser(), app.CreateRoom)
+		v1.GET("/join_room", app.AuthenticateUser(), app.JoinRoom)
+		v1.POST("/report_question", app.AuthenticateUser(), app.ReportQuestion)
+		v1.GET("/topics", app.GetCatalog)
+		v1.GET("/profile", app.AuthenticateUser(), app.GetMyProfile)
+		v1.PUT("/profile", app.AuthenticateUser(), app.UpdateMyProfile)
+		v1.GET("/players/:id", app.AuthenticateUser(), app.GetPlayerProfile)
+		v1.GET("/players/:id/stats", app.AuthenticateUser(), app.GetPlayerStatsHandler)
+		v1.GET("/stats", app.AuthenticateUser(), app.GetMyStats)
+		v1.GET("/history", app.AuthenticateUser(), app.GetMyMatchHistory)
+		v1.GET("/leaderboard", app.AuthenticateUser(), app.GetLeaderboardHandler)
+		v1.GET("/friends", app.AuthenticateUser(), app.GetFriends)
+		v1.DELETE("/friends/:id", app.AuthenticateUser(), app.DeleteFriend)
+		v1.POST("/friends/:id/challenge", app.AuthenticateUser(), app.ChallengeFriendHandler)
+		v1.POST("/friend_requests", app.AuthenticateUser(), app.AddFriend)
+		v1.PUT("/friend_requests/:id", app.AuthenticateUser(), app.AnswerFriendRequestHandler)
+		v1.POST("/discover_friends", app.AuthenticateUser(), app.DiscoverFriendsHandler)
+		v1.GET("/notifications", app.AuthenticateUser(), app.GetMyNotifications)
+		v1.GET("/async_games", app.AuthenticateUser(), app.GetAsyncGamesHandler)
+		v1.POST("/async_games", app.AuthenticateUser(), app.CreateAsyncGameHandler)
+		v1.GET("/async_games/:id/question", app.AuthenticateUser(), app.GetAsyncQuestion)
+		v1.POST("/async_games/:id/answer", app.AuthenticateUser(), app.AnswerAsyncQuestionHandler)
+		v1.POST("/async_games/:id/lifeline", app.AuthenticateUser(), app.UseAsyncLifelineHandler)
+		v1.GET("/daily_challenge", app.AuthenticateUser(), app.GetDailyChallenge)
+		v1.POST("/daily_challenge", app.AuthenticateUser(), app.PlayDailyChallenge)
+		v1.GET("/daily_challenge/leaderboard", app.AuthenticateUser(), app.GetDailyChallengeLeaderboard)
+		v1.GET("/tournaments", app.AuthenticateUser(), app.GetTournamentsHandler)
+		v1.GET("/tournaments/:id", app.AuthenticateUser(), app.GetTournamentHandler)
+		v1.POST("/tournaments/:id/register", app.AuthenticateUser(), app.RegisterForTournamentHandler)
+		v1.GET("/games/:id/replay", app.AuthenticateUser(), app.GetGameReplay)
 	}
-	v2 := router.Group("/api/admin")
+	router.POST("/api/admin/login", admin.LoginAdmin)
+	v2 := router.Group("/api/admin", admin.Authenticate(), admin.Audit())
 	{
-		v2.GET("/questions ", admin.GetQuestions)
-		v2.GET("/game", admin.GetGame)
-		v2.GET("/otp", admin.GetOtp)
-		v2.GET("/create_game", admin.CreateGame)
-		v2.GET("/room", admin.CreateRoom)
+		viewer := admin.RequireRole(admin.Viewer)
+		editor := admin.RequireRole(admin.ContentEditor)
+		superadmin := admin.RequireRole(admin.Superadmin)
+		v2.POST("/logout", admin.LogoutAdmin)
+		v2.GET("/questions", viewer, admin.GetQuestions)
+		v2.POST("/questions", editor, admin.CreateQuestion)
+		v2.GET("/questions/:id", viewer, admin.GetQuestion)
+		v2.PUT("/questions/:id", editor, admin.UpdateQuestion)
+		v2.DELETE("/questions/:id", editor, admin.DeleteQuestion)
+		v2.PUT("/questions/:id/state", editor, admin.SetQuestionState)
+		v2.PUT("/questions/:id/explanations", editor, admin.SetExplanations)
+		v2.GET("/catalog", viewer, admin.GetCatalog)
+		v2.POST("/topics", editor, admin.SaveTopic)
+		v2.PUT("/topics/:id", editor, admin.SaveTopic)
+		v2.POST("/topics/:id/image", editor, admin.UploadTopicImage)
+		v2.POST("/languages", editor, admin.SaveLanguage)
+		v2.PUT("/languages/:id", editor, admin.SaveLanguage)
+		v2.PUT("/daily_challenge", editor, admin.CurateDailyChallenge)
+		v2.POST("/tournaments", editor, admin.CreateTournament)
+		v2.GET("/reports", viewer, admin.GetReports)
+		v2.PUT("/reports/:id", editor, admin.ResolveReport)
+		v2.POST("/import_questions", editor, admin.ImportQuestions)
+		v2.GET("/export_questions", viewer, admin.ExportQuestions)
+		v2.GET("/game", viewer, admin.GetGame)
+		v2.GET("/game/replay", viewer, admin.GetGameReplay)
+		v2.PUT("/game/advance", superadmin, admin.AdvanceGame)
+		v2.PUT("/game/finish", superadmin, admin.FinishGame)
+		v2.PUT("/game/void", superadmin, admin.VoidGame)
+		v2.PUT("/game/adjust_score", superadmin, admin.AdjustScore)
+		v2.GET("/active_games", viewer, admin.GetActiveGames)
+		v2.GET("/waiting_players", viewer, admin.GetWaitingPlayers)
+		v2.GET("/game_events", viewer, admin.StreamGameEvents)
+		v2.GET("/otp", superadmin, admin.GetOtp)
+		v2.GET("/create_game", superadmin, admin.CreateGame)
+		v2.GET("/room", superadmin, admin.CreateRoom)
+		v2.GET("/accounts", superadmin, admin.GetAccountList)
+		v2.POST("/accounts", superadmin, admin.AddAccount)
+		v2.PUT("/accounts/:username", superadmin, admin.UpdateAccount)
+		v2.DELETE("/accounts/:username", superadmin, admin.RemoveAccount)
+		v2.GET("/audit_log", superadmin, admin.GetAudit)
 	}
 	database.InitRedis()
-	database.InitElastic()
+	admin.InitAdmin()
+	app.InitPhoneHashes()
+	app.InitCatalog()
+	go app.StartCatalogRefresh(time.Minute)
+	app.InitQuestionRepository()
+	go app.StartDifficultyCalibration(time.Hour)
+	go app.StartLeaderboardRollover(time.Minute)
+	go app.StartAsyncGameExpiry(time.Minute)
+	go app.StartTournamentScheduler(time.Minute)
 	go socket.InitPlayerJoinSocket()
 	go socket.InitGameSocket()
 	err := router.Run(os.Getenv("PORT"))